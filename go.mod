@@ -7,4 +7,5 @@ require (
 	github.com/hashicorp/terraform-plugin-sdk/v2 v2.0.4
 	github.com/mitchellh/hashstructure v1.0.0
 	github.com/sourcegraph/jsonrpc2 v0.0.0-20200429184054-15c2290dcb37
+	golang.org/x/net v0.0.0-20200707034311-ab3426394381
 )
@@ -18,23 +18,45 @@ func dataSourceNetwork() *schema.Resource {
 				Required: true,
 			},
 			"name": {
-				Type:     schema.TypeString,
-				Required: true,
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				AtLeastOneOf: []string{"name", "filter"},
 			},
 			"description": {
 				Type:     schema.TypeString,
 				Computed: true,
 			},
+			"filter": dataSourceFilterSchema(),
 		},
 	}
 }
 
 func dataSourceNetworkRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	c := m.(*xo_client.Client)
+	c := m.(*providerMeta).client
 
 	poolID := d.Get("pool_id").(string)
 	name := d.Get("name").(string)
-	network, err := c.GetNetworkByName(ctx, poolID, name)
+
+	query := xo_client.ObjectQuery{
+		"$pool": poolID,
+	}
+	if len(name) > 0 {
+		query["name_label"] = name
+	}
+
+	filter, err := dataSourceFilter(d, query)
+	if err != nil {
+		return diag.Diagnostics{
+			{
+				Severity: diag.Error,
+				Summary:  "Invalid filter",
+				Detail:   err.Error(),
+			},
+		}
+	}
+
+	network, err := c.FindNetwork(ctx, filter)
 	if err != nil {
 		return diag.Diagnostics{
 			{
@@ -46,6 +68,7 @@ func dataSourceNetworkRead(ctx context.Context, d *schema.ResourceData, m interf
 	}
 
 	d.SetId(network.ID)
+	d.Set("name", network.Name)
 	d.Set("description", network.Description)
 
 	return nil
@@ -0,0 +1,42 @@
+package xo
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestAccDataSourceNetwork(t *testing.T) {
+	f := testAccSetup(t)
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: f.dataSourceConfig("xenorchestra_network", map[string]interface{}{
+					"pool_id": f.poolID,
+					"name":    "Pool-wide network associated with eth1",
+				}),
+				Check: resource.TestCheckResourceAttr("data.xenorchestra_network.test", "id", f.otherNetworkID),
+			},
+			{
+				Config: f.dataSourceConfig("xenorchestra_network", map[string]interface{}{
+					"pool_id": f.poolID,
+					"filter": []interface{}{
+						map[string]interface{}{
+							"field":  "name_label",
+							"values": []interface{}{"Pool-wide network associated with eth1"},
+							"match":  "not_equals",
+						},
+						map[string]interface{}{
+							"field":  "MTU",
+							"values": []interface{}{"1500"},
+							"type":   "number",
+						},
+					},
+				}),
+				Check: resource.TestCheckResourceAttr("data.xenorchestra_network.test", "id", f.networkID),
+			},
+		},
+	})
+}
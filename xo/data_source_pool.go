@@ -14,22 +14,42 @@ func dataSourcePool() *schema.Resource {
 		ReadContext: dataSourcePoolRead,
 		Schema: map[string]*schema.Schema{
 			"name": {
-				Type:     schema.TypeString,
-				Required: true,
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				AtLeastOneOf: []string{"name", "filter"},
 			},
 			"description": {
 				Type:     schema.TypeString,
 				Computed: true,
 			},
+			"filter": dataSourceFilterSchema(),
 		},
 	}
 }
 
 func dataSourcePoolRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	c := m.(*xo_client.Client)
+	c := m.(*providerMeta).client
 
 	name := d.Get("name").(string)
-	pool, err := c.GetPoolByName(ctx, name)
+
+	query := xo_client.ObjectQuery{}
+	if len(name) > 0 {
+		query["name_label"] = name
+	}
+
+	filter, err := dataSourceFilter(d, query)
+	if err != nil {
+		return diag.Diagnostics{
+			{
+				Severity: diag.Error,
+				Summary:  "Invalid filter",
+				Detail:   err.Error(),
+			},
+		}
+	}
+
+	pool, err := c.FindPool(ctx, filter)
 	if err != nil {
 		return diag.Diagnostics{
 			{
@@ -41,6 +61,7 @@ func dataSourcePoolRead(ctx context.Context, d *schema.ResourceData, m interface
 	}
 
 	d.SetId(pool.ID)
+	d.Set("name", pool.Name)
 	d.Set("description", pool.Description)
 
 	return nil
@@ -0,0 +1,43 @@
+package xo
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestAccDataSourcePool(t *testing.T) {
+	f := testAccSetup(t)
+	f.server.AddPool("other pool")
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: f.dataSourceConfig("xenorchestra_pool", map[string]interface{}{
+					"name": "missing",
+				}),
+				ExpectError: regexp.MustCompile("Resource Not Found"),
+			},
+			{
+				Config: f.dataSourceConfig("xenorchestra_pool", map[string]interface{}{
+					"name": "pool",
+				}),
+				Check: resource.TestCheckResourceAttr("data.xenorchestra_pool.test", "id", f.poolID),
+			},
+			{
+				Config: f.dataSourceConfig("xenorchestra_pool", map[string]interface{}{
+					"filter": []interface{}{
+						map[string]interface{}{
+							"field":  "name_label",
+							"values": []interface{}{"other"},
+							"match":  "prefix",
+						},
+					},
+				}),
+				Check: resource.TestCheckResourceAttr("data.xenorchestra_pool.test", "name", "other pool"),
+			},
+		},
+	})
+}
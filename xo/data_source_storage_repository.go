@@ -18,23 +18,45 @@ func dataSourceStorageRepository() *schema.Resource {
 				Required: true,
 			},
 			"name": {
-				Type:     schema.TypeString,
-				Required: true,
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				AtLeastOneOf: []string{"name", "filter"},
 			},
 			"description": {
 				Type:     schema.TypeString,
 				Computed: true,
 			},
+			"filter": dataSourceFilterSchema(),
 		},
 	}
 }
 
 func dataSourceStorageRepositoryRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	c := m.(*xo_client.Client)
+	c := m.(*providerMeta).client
 
 	poolID := d.Get("pool_id").(string)
 	name := d.Get("name").(string)
-	sr, err := c.GetStorageRepositoryByName(ctx, poolID, name)
+
+	query := xo_client.ObjectQuery{
+		"$pool": poolID,
+	}
+	if len(name) > 0 {
+		query["name_label"] = name
+	}
+
+	filter, err := dataSourceFilter(d, query)
+	if err != nil {
+		return diag.Diagnostics{
+			{
+				Severity: diag.Error,
+				Summary:  "Invalid filter",
+				Detail:   err.Error(),
+			},
+		}
+	}
+
+	sr, err := c.FindStorageRepository(ctx, filter)
 	if err != nil {
 		return diag.Diagnostics{
 			{
@@ -46,6 +68,7 @@ func dataSourceStorageRepositoryRead(ctx context.Context, d *schema.ResourceData
 	}
 
 	d.SetId(sr.ID)
+	d.Set("name", sr.Name)
 	d.Set("description", sr.Description)
 
 	return nil
@@ -0,0 +1,36 @@
+package xo
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestAccDataSourceStorageRepository(t *testing.T) {
+	f := testAccSetup(t)
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: f.dataSourceConfig("xenorchestra_storage_repository", map[string]interface{}{
+					"pool_id": f.poolID,
+					"name":    "Local storage",
+				}),
+				Check: resource.TestCheckResourceAttr("data.xenorchestra_storage_repository.test", "id", f.storageRepositoryID),
+			},
+			{
+				Config: f.dataSourceConfig("xenorchestra_storage_repository", map[string]interface{}{
+					"pool_id": f.poolID,
+					"filter": []interface{}{
+						map[string]interface{}{
+							"field":  "SR_type",
+							"values": []interface{}{"iso"},
+						},
+					},
+				}),
+				Check: resource.TestCheckResourceAttr("data.xenorchestra_storage_repository.test", "id", f.isoStorageRepositoryID),
+			},
+		},
+	})
+}
@@ -18,23 +18,45 @@ func dataSourceTemplate() *schema.Resource {
 				Required: true,
 			},
 			"name": {
-				Type:     schema.TypeString,
-				Required: true,
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				AtLeastOneOf: []string{"name", "filter"},
 			},
 			"description": {
 				Type:     schema.TypeString,
 				Computed: true,
 			},
+			"filter": dataSourceFilterSchema(),
 		},
 	}
 }
 
 func dataSourceTemplateRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	c := m.(*xo_client.Client)
+	c := m.(*providerMeta).client
 
 	poolID := d.Get("pool_id").(string)
 	name := d.Get("name").(string)
-	template, err := c.GetTemplateByName(ctx, poolID, name)
+
+	query := xo_client.ObjectQuery{
+		"$pool": poolID,
+	}
+	if len(name) > 0 {
+		query["name_label"] = name
+	}
+
+	filter, err := dataSourceFilter(d, query)
+	if err != nil {
+		return diag.Diagnostics{
+			{
+				Severity: diag.Error,
+				Summary:  "Invalid filter",
+				Detail:   err.Error(),
+			},
+		}
+	}
+
+	template, err := c.FindTemplate(ctx, filter)
 	if err != nil {
 		return diag.Diagnostics{
 			{
@@ -46,6 +68,7 @@ func dataSourceTemplateRead(ctx context.Context, d *schema.ResourceData, m inter
 	}
 
 	d.SetId(template.ID)
+	d.Set("name", template.Name)
 	d.Set("description", template.Description)
 
 	return nil
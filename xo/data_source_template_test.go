@@ -0,0 +1,46 @@
+package xo
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestAccDataSourceTemplate(t *testing.T) {
+	f := testAccSetup(t)
+	otherPoolID := f.server.AddPool("other pool")
+	f.server.AddTemplate(otherPoolID, "Debian Buster 10")
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: f.dataSourceConfig("xenorchestra_template", map[string]interface{}{
+					"pool_id": f.poolID,
+				}),
+				ExpectError: regexp.MustCompile("one of `filter,name` must be specified"),
+			},
+			{
+				Config: f.dataSourceConfig("xenorchestra_template", map[string]interface{}{
+					"pool_id": f.poolID,
+					"name":    "Debian Buster 10",
+				}),
+				Check: resource.TestCheckResourceAttr("data.xenorchestra_template.test", "id", f.templateID),
+			},
+			{
+				Config: f.dataSourceConfig("xenorchestra_template", map[string]interface{}{
+					"pool_id": f.poolID,
+					"filter": []interface{}{
+						map[string]interface{}{
+							"field":  "name_label",
+							"values": []interface{}{"^Other"},
+							"match":  "regex",
+						},
+					},
+				}),
+				Check: resource.TestCheckResourceAttr("data.xenorchestra_template.test", "id", f.emptyTemplateID),
+			},
+		},
+	})
+}
@@ -18,23 +18,45 @@ func dataSourceDisk() *schema.Resource {
 				Required: true,
 			},
 			"name": {
-				Type:     schema.TypeString,
-				Required: true,
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				AtLeastOneOf: []string{"name", "filter"},
 			},
 			"description": {
 				Type:     schema.TypeString,
 				Computed: true,
 			},
+			"filter": dataSourceFilterSchema(),
 		},
 	}
 }
 
 func dataSourceDiskRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	c := m.(*xo_client.Client)
+	c := m.(*providerMeta).client
 
 	storageRepositoryID := d.Get("storage_repository_id").(string)
 	name := d.Get("name").(string)
-	vdi, err := c.GetVDIByName(ctx, storageRepositoryID, name)
+
+	query := xo_client.ObjectQuery{
+		"$SR": storageRepositoryID,
+	}
+	if len(name) > 0 {
+		query["name_label"] = name
+	}
+
+	filter, err := dataSourceFilter(d, query)
+	if err != nil {
+		return diag.Diagnostics{
+			{
+				Severity: diag.Error,
+				Summary:  "Invalid filter",
+				Detail:   err.Error(),
+			},
+		}
+	}
+
+	vdi, err := c.FindVDI(ctx, filter)
 	if err != nil {
 		return diag.Diagnostics{
 			{
@@ -46,6 +68,7 @@ func dataSourceDiskRead(ctx context.Context, d *schema.ResourceData, m interface
 	}
 
 	d.SetId(vdi.ID)
+	d.Set("name", vdi.Name)
 	d.Set("description", vdi.Description)
 
 	return nil
@@ -0,0 +1,48 @@
+package xo
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestAccDataSourceDisk(t *testing.T) {
+	f := testAccSetup(t)
+	f.server.AddVDI(f.isoStorageRepositoryID, "ubuntu-20.04.1-live-server-amd64.iso", 1*testAccGiB)
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				// both isos match
+				Config: f.dataSourceConfig("xenorchestra_disk", map[string]interface{}{
+					"storage_repository_id": f.isoStorageRepositoryID,
+					"filter": []interface{}{
+						map[string]interface{}{
+							"field":  "name_label",
+							"values": []interface{}{"amd64"},
+							"match":  "regex",
+						},
+					},
+				}),
+				ExpectError: regexp.MustCompile("Multiple Resources Found"),
+			},
+			{
+				// the iso is not in the other storage repository
+				Config: f.dataSourceConfig("xenorchestra_disk", map[string]interface{}{
+					"storage_repository_id": f.storageRepositoryID,
+					"name":                  "debian-10.6.0-amd64-netinst.iso",
+				}),
+				ExpectError: regexp.MustCompile("Resource Not Found"),
+			},
+			{
+				Config: f.dataSourceConfig("xenorchestra_disk", map[string]interface{}{
+					"storage_repository_id": f.isoStorageRepositoryID,
+					"name":                  "debian-10.6.0-amd64-netinst.iso",
+				}),
+				Check: resource.TestCheckResourceAttr("data.xenorchestra_disk.test", "id", f.isoID),
+			},
+		},
+	})
+}
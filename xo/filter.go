@@ -0,0 +1,107 @@
+package xo
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	"github.com/rmb938/terraform-provider-xenorchestra/xo_client"
+)
+
+func dataSourceFilterSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:     schema.TypeList,
+		Optional: true,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"field": {
+					Type:     schema.TypeString,
+					Required: true,
+				},
+				"values": {
+					Type:     schema.TypeList,
+					Required: true,
+					MinItems: 1,
+					Elem: &schema.Schema{
+						Type: schema.TypeString,
+					},
+				},
+				"match": {
+					Type:         schema.TypeString,
+					Optional:     true,
+					Default:      "equals",
+					ValidateFunc: validation.StringInSlice([]string{"equals", "not_equals", "contains", "prefix", "regex"}, false),
+				},
+				"type": {
+					Type:         schema.TypeString,
+					Optional:     true,
+					Default:      "string",
+					ValidateFunc: validation.StringInSlice([]string{"string", "number", "bool"}, false),
+				},
+			},
+		},
+	}
+}
+
+// dataSourceFilter combines query with the filter blocks of the data source. The values of
+// a block are alternatives, all blocks have to match.
+func dataSourceFilter(d *schema.ResourceData, query xo_client.ObjectQuery) (xo_client.Filter, error) {
+	filters := []xo_client.Filter{query}
+
+	filterList := d.Get("filter").([]interface{})
+	for _, filter := range filterList {
+		filterMap := filter.(map[string]interface{})
+		field := filterMap["field"].(string)
+		match := filterMap["match"].(string)
+		valueType := filterMap["type"].(string)
+
+		if (match == "prefix" || match == "regex") && valueType != "string" {
+			return nil, fmt.Errorf("filter on %s: %s only works with string values", field, match)
+		}
+
+		var alternatives []xo_client.Filter
+		for _, v := range filterMap["values"].([]interface{}) {
+			raw := v.(string)
+
+			var value interface{}
+			var err error
+			switch valueType {
+			case "number":
+				value, err = strconv.ParseFloat(raw, 64)
+			case "bool":
+				value, err = strconv.ParseBool(raw)
+			default:
+				value = raw
+			}
+			if err != nil {
+				return nil, fmt.Errorf("filter on %s: invalid %s value %q: %w", field, valueType, raw, err)
+			}
+
+			switch match {
+			case "contains":
+				alternatives = append(alternatives, xo_client.Contains(field, value))
+			case "prefix":
+				alternatives = append(alternatives, xo_client.HasPrefix(field, raw))
+			case "regex":
+				re, err := regexp.Compile(raw)
+				if err != nil {
+					return nil, fmt.Errorf("filter on %s: invalid regex %q: %w", field, raw, err)
+				}
+				alternatives = append(alternatives, xo_client.MatchesRegexp(field, re))
+			default:
+				alternatives = append(alternatives, xo_client.Eq(field, value))
+			}
+		}
+
+		if match == "not_equals" {
+			filters = append(filters, xo_client.Not(xo_client.Or(alternatives...)))
+		} else {
+			filters = append(filters, xo_client.Or(alternatives...))
+		}
+	}
+
+	return xo_client.And(filters...), nil
+}
@@ -0,0 +1,70 @@
+package xo
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// mutexKV is a registry of mutexes by key, used to serialize operations on the same XO
+// object across resources that Terraform applies in parallel.
+//
+// Locks have to be taken in the order VM then VDI to avoid deadlocks.
+type mutexKV struct {
+	mu    sync.Mutex
+	store map[string]chan struct{}
+}
+
+func newMutexKV() *mutexKV {
+	return &mutexKV{
+		store: map[string]chan struct{}{},
+	}
+}
+
+// Lock waits for the lock on key until ctx is done, the resource timeouts also bound the
+// time spent waiting on another resource
+func (m *mutexKV) Lock(ctx context.Context, key string) error {
+	select {
+	case m.get(key) <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (m *mutexKV) Unlock(key string) {
+	<-m.get(key)
+}
+
+func (m *mutexKV) get(key string) chan struct{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sem, ok := m.store[key]
+	if !ok {
+		sem = make(chan struct{}, 1)
+		m.store[key] = sem
+	}
+	return sem
+}
+
+// lockDiags reports a lock that wasn't acquired before the operation timed out
+func lockDiags(key string, err error) diag.Diagnostics {
+	return diag.Diagnostics{
+		{
+			Severity: diag.Error,
+			Summary:  fmt.Sprintf("Error waiting for the lock on %s", key),
+			Detail:   err.Error(),
+		},
+	}
+}
+
+func vmLockKey(id string) string {
+	return "vm/" + id
+}
+
+func vdiLockKey(id string) string {
+	return "vdi/" + id
+}
@@ -0,0 +1,91 @@
+package xo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMutexKV(t *testing.T) {
+	m := newMutexKV()
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	inside := 0
+	maxInside := 0
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if err := m.Lock(ctx, vmLockKey("vm")); err != nil {
+				t.Error(err)
+				return
+			}
+			defer m.Unlock(vmLockKey("vm"))
+
+			mu.Lock()
+			inside++
+			if inside > maxInside {
+				maxInside = inside
+			}
+			mu.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			mu.Lock()
+			inside--
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if maxInside != 1 {
+		t.Fatalf("expected operations on the same key to be serialized, %d ran at once", maxInside)
+	}
+
+	// other keys are independent
+	if err := m.Lock(ctx, vmLockKey("vm")); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		if err := m.Lock(ctx, vdiLockKey("vm")); err == nil {
+			m.Unlock(vdiLockKey("vm"))
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("lock on another key blocked")
+	}
+	m.Unlock(vmLockKey("vm"))
+}
+
+func TestMutexKVTimeout(t *testing.T) {
+	m := newMutexKV()
+
+	if err := m.Lock(context.Background(), vmLockKey("vm")); err != nil {
+		t.Fatal(err)
+	}
+
+	// waiting gives up with the context
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := m.Lock(ctx, vmLockKey("vm")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the lock to time out, got %v", err)
+	}
+
+	// without taking the lock
+	m.Unlock(vmLockKey("vm"))
+	if err := m.Lock(context.Background(), vmLockKey("vm")); err != nil {
+		t.Fatal(err)
+	}
+	m.Unlock(vmLockKey("vm"))
+}
@@ -2,14 +2,27 @@ package xo
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
 	"net/url"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 
 	"github.com/rmb938/terraform-provider-xenorchestra/xo_client"
 )
 
+// providerMeta is shared by all resources and data sources of a configured provider
+type providerMeta struct {
+	client *xo_client.Client
+	locks  *mutexKV
+}
+
 func Provider() *schema.Provider {
 	return &schema.Provider{
 		Schema: map[string]*schema.Schema{
@@ -19,20 +32,130 @@ func Provider() *schema.Provider {
 				DefaultFunc: schema.EnvDefaultFunc("XOA_URL", nil),
 			},
 			"username": {
-				Type:        schema.TypeString,
-				Required:    true,
-				DefaultFunc: schema.EnvDefaultFunc("XOA_USER", nil),
+				Type:          schema.TypeString,
+				Optional:      true,
+				DefaultFunc:   schema.EnvDefaultFunc("XOA_USER", nil),
+				ConflictsWith: []string{"token"},
 			},
 			"password": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				Sensitive:     true,
+				DefaultFunc:   schema.EnvDefaultFunc("XOA_PASSWORD", nil),
+				ConflictsWith: []string{"token"},
+			},
+			"token": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				Sensitive:     true,
+				DefaultFunc:   schema.EnvDefaultFunc("XOA_TOKEN", nil),
+				ConflictsWith: []string{"username", "password"},
+			},
+			"ca_cert_pem": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"ca_cert_file"},
+			},
+			"ca_cert_file": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				DefaultFunc:   schema.EnvDefaultFunc("XOA_CA_CERT_FILE", nil),
+				ConflictsWith: []string{"ca_cert_pem"},
+			},
+			"client_cert_pem": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				RequiredWith: []string{"client_key_pem"},
+			},
+			"client_key_pem": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Sensitive:    true,
+				RequiredWith: []string{"client_cert_pem"},
+			},
+			"insecure_skip_verify": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("XOA_INSECURE_SKIP_VERIFY", false),
+			},
+			"cert_fingerprint_sha256": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"proxy_url": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("XOA_PROXY_URL", nil),
+				ValidateFunc: validation.IsURLWithScheme([]string{"http", "https", "socks5", "socks5h"}),
+			},
+			"object_cache": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("XOA_OBJECT_CACHE", false),
+			},
+			"max_concurrent_requests": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("XOA_MAX_CONCURRENT_REQUESTS", 0),
+				ValidateFunc: validation.IntAtLeast(0),
+			},
+			"requests_per_second": {
+				Type:         schema.TypeFloat,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("XOA_REQUESTS_PER_SECOND", 0),
+				ValidateFunc: validation.FloatAtLeast(0),
+			},
+			"retry_max_attempts": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("XOA_RETRY_MAX_ATTEMPTS", xo_client.DefaultRetryPolicy.MaxAttempts),
+				ValidateFunc: validation.IntAtLeast(1),
+			},
+			"retry_initial_backoff": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      xo_client.DefaultRetryPolicy.InitialBackoff.String(),
+				ValidateFunc: validateDuration,
+			},
+			"retry_max_backoff": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      xo_client.DefaultRetryPolicy.MaxBackoff.String(),
+				ValidateFunc: validateDuration,
+			},
+			// replaces the default codes of every method, including the ones the defaults never
+			// retry like vm.create
+			"retry_error_codes": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			// comma separated codes by method, an empty list never retries the method
+			"retry_method_error_codes": {
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"request_logging": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("XOA_REQUEST_LOGGING", false),
+			},
+			"record_cassette": {
 				Type:        schema.TypeString,
-				Required:    true,
-				Sensitive:   true,
-				DefaultFunc: schema.EnvDefaultFunc("XOA_PASSWORD", nil),
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("XOA_RECORD_CASSETTE", nil),
 			},
 		},
 		ResourcesMap: map[string]*schema.Resource{
-			"xenorchestra_virtual_machine": resourceVirtualMachine(),
-			"xenorchestra_disk":            resourceDisk(),
+			"xenorchestra_virtual_machine":   resourceVirtualMachine(),
+			"xenorchestra_disk":              resourceDisk(),
+			"xenorchestra_network_interface": resourceNetworkInterface(),
+			"xenorchestra_disk_attachment":   resourceDiskAttachment(),
 		},
 		DataSourcesMap: map[string]*schema.Resource{
 			"xenorchestra_pool":               dataSourcePool(),
@@ -49,9 +172,37 @@ func providerConfigure(ctx context.Context, d *schema.ResourceData) (interface{}
 	urlString := d.Get("url").(string)
 	username := d.Get("username").(string)
 	password := d.Get("password").(string)
+	token := d.Get("token").(string)
 
 	var diags diag.Diagnostics
 
+	if len(token) > 0 && (len(username) > 0 || len(password) > 0) {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Conflicting credentials",
+			Detail:   "Only one of token or username and password can be set",
+		})
+		return nil, diags
+	}
+
+	if len(token) == 0 && len(username) == 0 && len(password) == 0 {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Missing credentials",
+			Detail:   "Either token or username and password must be set",
+		})
+		return nil, diags
+	}
+
+	if len(token) == 0 && (len(username) == 0 || len(password) == 0) {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Missing credentials",
+			Detail:   "Both username and password must be set",
+		})
+		return nil, diags
+	}
+
 	parsedURL, err := url.Parse(urlString)
 	if err != nil {
 		diags = append(diags, diag.Diagnostic{
@@ -71,25 +222,180 @@ func providerConfigure(ctx context.Context, d *schema.ResourceData) (interface{}
 		return nil, diags
 	}
 
-	c, err := xo_client.NewClient(parsedURL)
+	var opts []xo_client.ClientOption
+	if d.Get("object_cache").(bool) {
+		opts = append(opts, xo_client.WithObjectCache())
+	}
+
+	caCertPEM := d.Get("ca_cert_pem").(string)
+	if caCertFile := d.Get("ca_cert_file").(string); len(caCertFile) > 0 {
+		caCertBytes, err := ioutil.ReadFile(caCertFile)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Unable to read ca_cert_file",
+				Detail:   err.Error(),
+			})
+			return nil, diags
+		}
+		caCertPEM = string(caCertBytes)
+	}
+
+	if len(caCertPEM) > 0 {
+		opts = append(opts, xo_client.WithCACertificates([]byte(caCertPEM)))
+	}
+
+	if clientCertPEM := d.Get("client_cert_pem").(string); len(clientCertPEM) > 0 {
+		opts = append(opts, xo_client.WithClientCertificate([]byte(clientCertPEM), []byte(d.Get("client_key_pem").(string))))
+	}
+
+	if d.Get("insecure_skip_verify").(bool) {
+		opts = append(opts, xo_client.WithInsecureSkipVerify())
+	}
+
+	if fingerprint := d.Get("cert_fingerprint_sha256").(string); len(fingerprint) > 0 {
+		opts = append(opts, xo_client.WithCertificateFingerprint(fingerprint))
+	}
+
+	if proxyURLString := d.Get("proxy_url").(string); len(proxyURLString) > 0 {
+		proxyURL, err := url.Parse(proxyURLString)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Invalid proxy URL",
+				Detail:   err.Error(),
+			})
+			return nil, diags
+		}
+		opts = append(opts, xo_client.WithProxy(proxyURL))
+	}
+
+	if maxConcurrentRequests := d.Get("max_concurrent_requests").(int); maxConcurrentRequests > 0 {
+		opts = append(opts, xo_client.WithMaxConcurrentRequests(maxConcurrentRequests))
+	}
+
+	if requestsPerSecond := d.Get("requests_per_second").(float64); requestsPerSecond > 0 {
+		opts = append(opts, xo_client.WithRequestsPerSecond(requestsPerSecond))
+	}
+
+	retryPolicy := xo_client.DefaultRetryPolicy
+	retryPolicy.MaxAttempts = d.Get("retry_max_attempts").(int)
+	// both are validated by the schema
+	retryPolicy.InitialBackoff, _ = time.ParseDuration(d.Get("retry_initial_backoff").(string))
+	retryPolicy.MaxBackoff, _ = time.ParseDuration(d.Get("retry_max_backoff").(string))
+	// retry_error_codes drops the codes of single methods too, retry_method_error_codes then
+	// sets them again
+	if retryErrorCodes, ok := d.GetOk("retry_error_codes"); ok {
+		retryPolicy.RetryableCodes = nil
+		for _, code := range retryErrorCodes.([]interface{}) {
+			retryPolicy.RetryableCodes = append(retryPolicy.RetryableCodes, code.(string))
+		}
+		retryPolicy.MethodRetryableCodes = nil
+	}
+	if methodErrorCodes, ok := d.GetOk("retry_method_error_codes"); ok {
+		// the default map is shared, never change it
+		methodRetryableCodes := make(map[string][]string)
+		for method, codes := range retryPolicy.MethodRetryableCodes {
+			methodRetryableCodes[method] = codes
+		}
+		for method, codes := range methodErrorCodes.(map[string]interface{}) {
+			methodRetryableCodes[method] = nil
+			for _, code := range strings.Split(codes.(string), ",") {
+				if code = strings.TrimSpace(code); len(code) > 0 {
+					methodRetryableCodes[method] = append(methodRetryableCodes[method], code)
+				}
+			}
+		}
+		retryPolicy.MethodRetryableCodes = methodRetryableCodes
+	}
+	opts = append(opts, xo_client.WithRetryPolicy(retryPolicy))
+
+	if d.Get("request_logging").(bool) {
+		opts = append(opts, xo_client.WithRequestLogging())
+	}
+
+	if cassette := d.Get("record_cassette").(string); len(cassette) > 0 {
+		opts = append(opts, xo_client.WithRecording(cassette))
+	}
+
+	c, err := xo_client.NewClient(parsedURL, opts...)
 	if err != nil {
+		summary := "Unable to create XO Client"
+
+		var proxyErr *xo_client.ProxyError
+		if errors.As(err, &proxyErr) {
+			summary = fmt.Sprintf("Unable to reach XO through proxy %s while %s", proxyErr.Proxy.Redacted(), proxyErr.Hop)
+		}
+
 		diags = append(diags, diag.Diagnostic{
 			Severity: diag.Error,
-			Summary:  "Unable to create XO Client",
+			Summary:  summary,
 			Detail:   err.Error(),
 		})
 		return nil, diags
 	}
 
-	err = c.SignIn(ctx, username, password)
+	if len(token) > 0 {
+		err = c.SignInWithToken(ctx, token)
+	} else {
+		err = c.SignIn(ctx, username, password)
+	}
 	if err != nil {
+		summary := "Error signing into XO API"
+		if errors.Is(err, xo_client.UnauthorizedError) {
+			summary = "XO API rejected the provided credentials"
+		}
+
 		diags = append(diags, diag.Diagnostic{
 			Severity: diag.Error,
-			Summary:  "Error signing into XO API",
+			Summary:  summary,
 			Detail:   err.Error(),
 		})
 		return nil, diags
 	}
 
-	return c, diags
+	return &providerMeta{
+		client: c,
+		locks:  newMutexKV(),
+	}, diags
+}
+
+// validateMACAddress checks for a unicast MAC address like 02:00:00:aa:bb:cc
+func validateMACAddress(i interface{}, k string) (warnings []string, errors []error) {
+	v, ok := i.(string)
+	if !ok {
+		errors = append(errors, fmt.Errorf("expected type of %s to be string", k))
+		return warnings, errors
+	}
+
+	mac, err := net.ParseMAC(v)
+	if err != nil || len(mac) != 6 || !strings.Contains(v, ":") {
+		errors = append(errors, fmt.Errorf("expected %s to be a MAC address like 02:00:00:aa:bb:cc, got %q", k, v))
+		return warnings, errors
+	}
+
+	// the least significant bit of the first octet marks multicast addresses
+	if mac[0]&1 == 1 {
+		errors = append(errors, fmt.Errorf("expected %s to be a unicast MAC address, got multicast %q", k, v))
+	}
+
+	if mac.String() == "00:00:00:00:00:00" {
+		errors = append(errors, fmt.Errorf("expected %s to be a unicast MAC address, got %q", k, v))
+	}
+
+	return warnings, errors
+}
+
+func validateDuration(i interface{}, k string) (warnings []string, errors []error) {
+	v, ok := i.(string)
+	if !ok {
+		errors = append(errors, fmt.Errorf("expected type of %s to be string", k))
+		return warnings, errors
+	}
+
+	if _, err := time.ParseDuration(v); err != nil {
+		errors = append(errors, fmt.Errorf("expected %s to be a duration like 2s or 1m, got %q: %v", k, v, err))
+	}
+
+	return warnings, errors
 }
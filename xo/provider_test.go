@@ -0,0 +1,419 @@
+package xo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+
+	"github.com/rmb938/terraform-provider-xenorchestra/xo_client"
+	"github.com/rmb938/terraform-provider-xenorchestra/xo_client/xotest"
+)
+
+const (
+	testAccUsername = "admin@admin.net"
+	testAccPassword = "admin"
+	testAccToken    = "test-token"
+	testAccGiB      = 1024 * 1024 * 1024
+)
+
+func TestProvider(t *testing.T) {
+	if err := Provider().InternalValidate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testAccFixtures is the infrastructure every acceptance test starts with
+type testAccFixtures struct {
+	server *xotest.Server
+
+	poolID                 string
+	storageRepositoryID    string
+	isoStorageRepositoryID string
+	networkID              string
+	otherNetworkID         string
+	templateID             string
+	emptyTemplateID        string
+	isoID                  string
+}
+
+func testAccSetup(t *testing.T) *testAccFixtures {
+	t.Helper()
+
+	s := xotest.NewServer()
+	t.Cleanup(s.Close)
+
+	s.AddUser(testAccUsername, testAccPassword)
+	s.AddToken(testAccToken)
+
+	f := &testAccFixtures{server: s}
+	f.poolID = s.AddPool("pool")
+	f.storageRepositoryID = s.AddStorageRepository(f.poolID, "Local storage", "ext")
+	f.isoStorageRepositoryID = s.AddStorageRepository(f.poolID, "ISOs", "iso")
+	f.networkID = s.AddNetwork(f.poolID, "Pool-wide network associated with eth0")
+	f.otherNetworkID = s.AddNetwork(f.poolID, "Pool-wide network associated with eth1")
+	f.templateID = s.AddTemplate(f.poolID, "Debian Buster 10", xotest.TemplateDisk{StorageRepositoryID: f.storageRepositoryID, Size: 10 * testAccGiB})
+	f.emptyTemplateID = s.AddTemplate(f.poolID, "Other install media")
+	f.isoID = s.AddVDI(f.isoStorageRepositoryID, "debian-10.6.0-amd64-netinst.iso", 1*testAccGiB)
+
+	return f
+}
+
+func (f *testAccFixtures) providerConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"url":      f.server.URL.String(),
+		"username": testAccUsername,
+		"password": testAccPassword,
+	}
+}
+
+// testAccProvider configures a provider against the fake server
+func (f *testAccFixtures) testAccProvider(t *testing.T) (*schema.Provider, interface{}) {
+	t.Helper()
+
+	p := Provider()
+	diags := p.Configure(context.Background(), terraform.NewResourceConfigRaw(f.providerConfig()))
+	if diags.HasError() {
+		t.Fatalf("configuring provider: %s", diagsString(diags))
+	}
+
+	return p, p.Meta()
+}
+
+var testAccProviderFactories = map[string]func() (*schema.Provider, error){
+	"xenorchestra": func() (*schema.Provider, error) {
+		return Provider(), nil
+	},
+}
+
+// config returns the provider block for the fake server followed by blocks
+func (f *testAccFixtures) config(blocks ...string) string {
+	config := testAccBlock("provider", "xenorchestra", "", f.providerConfig())
+	for _, block := range blocks {
+		config += "\n" + block
+	}
+	return config
+}
+
+// resourceConfig is config with the resource resourceType.test set to attrs
+func (f *testAccFixtures) resourceConfig(resourceType string, attrs map[string]interface{}) string {
+	return f.config(testAccBlock("resource", resourceType, "test", attrs))
+}
+
+// dataSourceConfig is config with the data source dataSourceType.test set to attrs
+func (f *testAccFixtures) dataSourceConfig(dataSourceType string, attrs map[string]interface{}) string {
+	return f.config(testAccBlock("data", dataSourceType, "test", attrs))
+}
+
+// testAccBlock renders attrs as a block of HCL. Lists of maps and maps become nested blocks,
+// nil values are left out.
+func testAccBlock(kind, blockType, name string, attrs map[string]interface{}) string {
+	header := fmt.Sprintf("%s %q", kind, blockType)
+	if len(name) > 0 {
+		header += fmt.Sprintf(" %q", name)
+	}
+
+	var b strings.Builder
+	b.WriteString(header + " {\n")
+	writeTestAccAttrs(&b, attrs, "  ")
+	b.WriteString("}\n")
+
+	return b.String()
+}
+
+func writeTestAccAttrs(b *strings.Builder, attrs map[string]interface{}, indent string) {
+	var keys []string
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		switch v := attrs[k].(type) {
+		case nil:
+		case map[string]interface{}:
+			writeTestAccBlock(b, k, v, indent)
+		case []interface{}:
+			if len(v) > 0 {
+				if _, ok := v[0].(map[string]interface{}); ok {
+					for _, item := range v {
+						writeTestAccBlock(b, k, item.(map[string]interface{}), indent)
+					}
+					continue
+				}
+			}
+
+			var items []string
+			for _, item := range v {
+				items = append(items, testAccValue(item))
+			}
+			fmt.Fprintf(b, "%s%s = [%s]\n", indent, k, strings.Join(items, ", "))
+		default:
+			fmt.Fprintf(b, "%s%s = %s\n", indent, k, testAccValue(v))
+		}
+	}
+}
+
+func writeTestAccBlock(b *strings.Builder, blockType string, attrs map[string]interface{}, indent string) {
+	fmt.Fprintf(b, "%s%s {\n", indent, blockType)
+	writeTestAccAttrs(b, attrs, indent+"  ")
+	fmt.Fprintf(b, "%s}\n", indent)
+}
+
+func testAccValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprint(v)
+}
+
+// withConfig returns a copy of config with key set to value
+func withConfig(config map[string]interface{}, key string, value interface{}) map[string]interface{} {
+	c := map[string]interface{}{}
+	for k, v := range config {
+		c[k] = v
+	}
+	c[key] = value
+	return c
+}
+
+// testAccCheckInstance runs check against the primary instance of the resource name
+func testAccCheckInstance(name string, check func(is *terraform.InstanceState) error) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[name]
+		if !ok || rs.Primary == nil {
+			return fmt.Errorf("%s not found in state", name)
+		}
+		return check(rs.Primary)
+	}
+}
+
+// testAccStoreID stores the ID of the resource name in id for later steps
+func testAccStoreID(name string, id *string) resource.TestCheckFunc {
+	return testAccCheckInstance(name, func(is *terraform.InstanceState) error {
+		*id = is.ID
+		return nil
+	})
+}
+
+// testAccCheckSameID checks that the resource name still has the ID stored in id
+func testAccCheckSameID(name string, id *string) resource.TestCheckFunc {
+	return testAccCheckInstance(name, func(is *terraform.InstanceState) error {
+		if is.ID != *id {
+			return fmt.Errorf("expected %s %s to be updated in place, got %s", name, *id, is.ID)
+		}
+		return nil
+	})
+}
+
+// testAccCheckNewID checks that the resource name was replaced since its ID was stored in id
+func testAccCheckNewID(name string, id *string) resource.TestCheckFunc {
+	return testAccCheckInstance(name, func(is *terraform.InstanceState) error {
+		if is.ID == *id {
+			return fmt.Errorf("expected %s %s to be replaced", name, *id)
+		}
+		return nil
+	})
+}
+
+// checkDestroyed checks that the objects of every resourceType in state are gone
+func (f *testAccFixtures) checkDestroyed(resourceType string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		for _, rs := range s.RootModule().Resources {
+			if rs.Type != resourceType {
+				continue
+			}
+			if f.server.Object(rs.Primary.ID) != nil {
+				return fmt.Errorf("%s %s still exists", resourceType, rs.Primary.ID)
+			}
+		}
+		return nil
+	}
+}
+
+func diagsString(diags diag.Diagnostics) string {
+	var msgs []string
+	for _, d := range diags {
+		msgs = append(msgs, strings.TrimSpace(d.Summary+": "+d.Detail))
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func TestAccProviderConfigure(t *testing.T) {
+	f := testAccSetup(t)
+
+	cases := []struct {
+		name   string
+		config map[string]interface{}
+		err    string
+	}{
+		{
+			name:   "password",
+			config: f.providerConfig(),
+		},
+		{
+			name: "token",
+			config: map[string]interface{}{
+				"url":   f.server.URL.String(),
+				"token": testAccToken,
+			},
+		},
+		{
+			name: "wrong password",
+			config: map[string]interface{}{
+				"url":      f.server.URL.String(),
+				"username": testAccUsername,
+				"password": "wrong",
+			},
+			err: "XO API rejected the provided credentials",
+		},
+		{
+			name: "wrong token",
+			config: map[string]interface{}{
+				"url":   f.server.URL.String(),
+				"token": "wrong",
+			},
+			err: "XO API rejected the provided credentials",
+		},
+		{
+			name: "missing password",
+			config: map[string]interface{}{
+				"url":      f.server.URL.String(),
+				"username": testAccUsername,
+			},
+			err: "Both username and password must be set",
+		},
+		{
+			name: "http url",
+			config: map[string]interface{}{
+				"url":   "http://" + f.server.URL.Host,
+				"token": testAccToken,
+			},
+			err: "Scheme must be ws or wss",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			diags := Provider().Configure(context.Background(), terraform.NewResourceConfigRaw(tc.config))
+
+			if len(tc.err) == 0 {
+				if diags.HasError() {
+					t.Fatalf("unexpected error: %s", diagsString(diags))
+				}
+				return
+			}
+
+			if !diags.HasError() || !strings.Contains(diagsString(diags), tc.err) {
+				t.Fatalf("expected error containing %q, got %q", tc.err, diagsString(diags))
+			}
+		})
+	}
+}
+
+// retryClient configures a provider with the retry attributes attrs and returns its client
+func (f *testAccFixtures) retryClient(t *testing.T, attrs map[string]interface{}) *xo_client.Client {
+	t.Helper()
+
+	config := f.providerConfig()
+	config["retry_initial_backoff"] = "1ms"
+	for k, v := range attrs {
+		config[k] = v
+	}
+
+	p := Provider()
+	diags := p.Configure(context.Background(), terraform.NewResourceConfigRaw(config))
+	if diags.HasError() {
+		t.Fatalf("configuring provider: %s", diagsString(diags))
+	}
+
+	return p.Meta().(*providerMeta).client
+}
+
+func TestAccProviderRetryErrorCodes(t *testing.T) {
+	f := testAccSetup(t)
+	ctx := context.Background()
+
+	vmID := f.createVirtualMachine(t, "test-vm", nil)
+	badPowerState := xotest.XapiError("VM_BAD_POWER_STATE", vmID, "halted", "running")
+	notEnoughMemory := xotest.XapiError("HOST_NOT_ENOUGH_FREE_MEMORY", "1073741824", "0")
+
+	// codes of single methods
+	c := f.retryClient(t, map[string]interface{}{
+		"retry_method_error_codes": map[string]interface{}{
+			"vm.start": "VM_BAD_POWER_STATE, HOST_NOT_ENOUGH_FREE_MEMORY",
+			"vm.stop":  "",
+		},
+	})
+
+	vm, err := c.GetVirtualMachineByID(ctx, vmID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.server.InjectFault(xotest.Fault{Method: "vm.start", Times: 1, Err: badPowerState})
+	if err := vm.Start(c, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if calls := len(f.server.Calls("vm.start")); calls != 2 {
+		t.Fatalf("expected VM_BAD_POWER_STATE to be retried on vm.start, got %d vm.start calls", calls)
+	}
+
+	f.server.InjectFault(xotest.Fault{Method: "vm.stop", Times: 1, Err: xotest.XapiError("OTHER_OPERATION_IN_PROGRESS", "VM", vmID)})
+	if err := vm.Stop(c, ctx, true); err == nil {
+		t.Fatal("expected vm.stop to fail")
+	}
+	if calls := len(f.server.Calls("vm.stop")); calls != 1 {
+		t.Fatalf("expected vm.stop not to be retried, got %d vm.stop calls", calls)
+	}
+
+	// the defaults are left alone
+	if codes, ok := xo_client.DefaultRetryPolicy.MethodRetryableCodes["vm.stop"]; !ok || len(codes) == 0 {
+		t.Fatalf("expected the default codes of vm.stop to be kept, got %v", codes)
+	}
+
+	// retry_error_codes applies to every method, creates included
+	c = f.retryClient(t, map[string]interface{}{
+		"retry_error_codes": []interface{}{"HOST_NOT_ENOUGH_FREE_MEMORY"},
+	})
+
+	template, err := c.GetTemplateByID(ctx, f.templateID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	creates := len(f.server.Calls("vm.create"))
+	f.server.InjectFault(xotest.Fault{Method: "vm.create", Times: 1, Err: notEnoughMemory})
+	if _, err := c.CreateVirtualMachine(ctx, "other-vm", "", template, 1, testAccGiB, nil, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if calls := len(f.server.Calls("vm.create")) - creates; calls != 2 {
+		t.Fatalf("expected HOST_NOT_ENOUGH_FREE_MEMORY to be retried on vm.create, got %d vm.create calls", calls)
+	}
+}
+
+func TestValidateMACAddress(t *testing.T) {
+	cases := map[string]bool{
+		"02:00:00:aa:bb:cc": true,
+		"02:00:00:AA:BB:CC": true,
+		"01:00:5e:00:00:01": false,
+		"ff:ff:ff:ff:ff:ff": false,
+		"00:00:00:00:00:00": false,
+		"0200.00aa.bbcc":    false,
+		"02:00:00:aa:bb":    false,
+		"not a mac":         false,
+	}
+
+	for mac, valid := range cases {
+		_, errs := validateMACAddress(mac, "mac_address")
+		if valid != (len(errs) == 0) {
+			t.Errorf("%s: expected valid %t, got %v", mac, valid, errs)
+		}
+	}
+}
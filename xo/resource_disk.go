@@ -2,6 +2,10 @@ package xo
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
@@ -17,6 +21,14 @@ func resourceDisk() *schema.Resource {
 		ReadContext:   resourceDiskRead,
 		UpdateContext: resourceDiskUpdate,
 		DeleteContext: resourceDiskDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceDiskImport,
+		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(20 * time.Minute),
+			Update: schema.DefaultTimeout(20 * time.Minute),
+			Delete: schema.DefaultTimeout(10 * time.Minute),
+		},
 		Schema: map[string]*schema.Schema{
 			"name": {
 				Type:     schema.TypeString,
@@ -50,7 +62,7 @@ func resourceDisk() *schema.Resource {
 }
 
 func resourceDiskCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	c := m.(*xo_client.Client)
+	c := m.(*providerMeta).client
 
 	name := d.Get("name").(string)
 	description := d.Get("description").(string)
@@ -97,12 +109,57 @@ func resourceDiskCreate(ctx context.Context, d *schema.ResourceData, m interface
 	return resourceDiskRead(ctx, d, m)
 }
 
+// resourceDiskImport imports a disk by its ID or by sr_name/vdi_name
+func resourceDiskImport(ctx context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	c := m.(*providerMeta).client
+
+	var vdi *xo_client.VDI
+	var err error
+
+	if parts := strings.SplitN(d.Id(), "/", 2); len(parts) == 2 {
+		var storageRepositories []xo_client.StorageRepository
+		storageRepositories, err = c.ListStorageRepositories(ctx, xo_client.ObjectQuery{"name_label": parts[0]})
+		if err != nil {
+			return nil, fmt.Errorf("finding storage repository %s: %w", parts[0], err)
+		}
+
+		// like Local storage, names are often the same on every host
+		if len(storageRepositories) > 1 {
+			var ids []string
+			for _, storageRepository := range storageRepositories {
+				ids = append(ids, fmt.Sprintf("%s in pool %s", storageRepository.ID, storageRepository.Pool))
+			}
+			return nil, fmt.Errorf("storage repository name %s is ambiguous, it matches %s: import the disk by its ID instead", parts[0], strings.Join(ids, ", "))
+		}
+		if len(storageRepositories) == 0 {
+			return nil, fmt.Errorf("finding storage repository %s: %w", parts[0], xo_client.NotFoundError)
+		}
+
+		vdi, err = c.GetVDIByName(ctx, storageRepositories[0].ID, parts[1])
+	} else {
+		vdi, err = c.GetVDIByID(ctx, d.Id())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	mode, err := vdi.GetMode(c, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	d.SetId(vdi.ID)
+	d.Set("mode", string(mode))
+
+	return []*schema.ResourceData{d}, nil
+}
+
 func resourceDiskRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	c := m.(*xo_client.Client)
+	c := m.(*providerMeta).client
 
 	vdi, err := c.GetVDIByID(ctx, d.Id())
 	if err != nil {
-		if err == xo_client.NotFoundError {
+		if errors.Is(err, xo_client.NotFoundError) {
 			d.SetId("")
 			return nil
 		}
@@ -122,11 +179,33 @@ func resourceDiskRead(ctx context.Context, d *schema.ResourceData, m interface{}
 	d.Set("size", vdi.Size/1024/1024/1024)
 	d.Set("storage_repository_id", vdi.StorageRepositoryID)
 
+	// XAPI keeps the mode on the VBDs, a disk that isn't attached keeps the mode it was
+	// created with
+	if len(vdi.VBDs) > 0 {
+		mode, err := vdi.GetMode(c, ctx)
+		if err != nil {
+			return diag.Diagnostics{
+				{
+					Severity: diag.Error,
+					Summary:  "Error getting disk mode",
+					Detail:   err.Error(),
+				},
+			}
+		}
+		d.Set("mode", string(mode))
+	}
+
 	return nil
 }
 
 func resourceDiskUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	c := m.(*xo_client.Client)
+	c := m.(*providerMeta).client
+
+	locks := m.(*providerMeta).locks
+	if err := locks.Lock(ctx, vdiLockKey(d.Id())); err != nil {
+		return lockDiags(vdiLockKey(d.Id()), err)
+	}
+	defer locks.Unlock(vdiLockKey(d.Id()))
 
 	var name *string
 	var description *string
@@ -134,7 +213,7 @@ func resourceDiskUpdate(ctx context.Context, d *schema.ResourceData, m interface
 
 	vdi, err := c.GetVDIByID(ctx, d.Id())
 	if err != nil {
-		if err == xo_client.NotFoundError {
+		if errors.Is(err, xo_client.NotFoundError) {
 			d.SetId("")
 			return nil
 		}
@@ -153,7 +232,7 @@ func resourceDiskUpdate(ctx context.Context, d *schema.ResourceData, m interface
 	}
 
 	if d.HasChange("description") {
-		name = func(i string) *string { return &i }(d.Get("description").(string))
+		description = func(i string) *string { return &i }(d.Get("description").(string))
 	}
 
 	if d.HasChange("size") {
@@ -175,11 +254,17 @@ func resourceDiskUpdate(ctx context.Context, d *schema.ResourceData, m interface
 }
 
 func resourceDiskDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	c := m.(*xo_client.Client)
+	c := m.(*providerMeta).client
+
+	locks := m.(*providerMeta).locks
+	if err := locks.Lock(ctx, vdiLockKey(d.Id())); err != nil {
+		return lockDiags(vdiLockKey(d.Id()), err)
+	}
+	defer locks.Unlock(vdiLockKey(d.Id()))
 
 	vdi, err := c.GetVDIByID(ctx, d.Id())
 	if err != nil {
-		if err == xo_client.NotFoundError {
+		if errors.Is(err, xo_client.NotFoundError) {
 			d.SetId("")
 			return nil
 		}
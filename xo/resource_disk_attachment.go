@@ -0,0 +1,359 @@
+package xo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	"github.com/rmb938/terraform-provider-xenorchestra/xo_client"
+)
+
+// resourceDiskAttachment is a VBD managed on its own, the attached_disk list of the VM's
+// xenorchestra_virtual_machine leaves it alone
+func resourceDiskAttachment() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: resourceDiskAttachmentCreate,
+		ReadContext:   resourceDiskAttachmentRead,
+		UpdateContext: resourceDiskAttachmentUpdate,
+		DeleteContext: resourceDiskAttachmentDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(10 * time.Minute),
+			Update: schema.DefaultTimeout(10 * time.Minute),
+			Delete: schema.DefaultTimeout(10 * time.Minute),
+		},
+		Schema: map[string]*schema.Schema{
+			"vm_id": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"disk_id": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"mode": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      string(xo_client.VDIModeRW),
+				ForceNew:     true,
+				ValidateFunc: validation.StringInSlice([]string{string(xo_client.VDIModeRO), string(xo_client.VDIModeRW)}, false),
+			},
+			"bootable": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			// position 0 is the boot disk of xenorchestra_virtual_machine
+			"position": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Computed: true,
+				ForceNew: true,
+				ValidateFunc: func(i interface{}, k string) ([]string, []error) {
+					if i.(string) == "0" {
+						return nil, []error{fmt.Errorf("%s can't be 0, it is the position of the boot disk", k)}
+					}
+					return nil, nil
+				},
+			},
+			"device": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"attached": {
+				Type:     schema.TypeBool,
+				Computed: true,
+			},
+		},
+		CustomizeDiff: customizeDiskAttachmentAttached,
+	}
+}
+
+// customizeDiskAttachmentAttached plans plugging the VBD back in when it was unplugged while
+// the VM is running
+func customizeDiskAttachmentAttached(ctx context.Context, diff *schema.ResourceDiff, m interface{}) error {
+	if len(diff.Id()) == 0 || diff.Get("attached").(bool) {
+		return nil
+	}
+
+	c := m.(*providerMeta).client
+
+	vm, err := c.GetVirtualMachineByID(ctx, diff.Get("vm_id").(string))
+	if err != nil {
+		if errors.Is(err, xo_client.NotFoundError) {
+			return nil
+		}
+		return err
+	}
+
+	if vm.PowerState == "Running" {
+		return diff.SetNew("attached", true)
+	}
+
+	return nil
+}
+
+// diskAttachmentVM gets the VM of the disk attachment and checks that a VBD can be plugged into
+// or unplugged from it
+func diskAttachmentVM(c *xo_client.Client, ctx context.Context, vmID string) (*xo_client.VirtualMachine, diag.Diagnostics) {
+	vm, err := c.GetVirtualMachineByID(ctx, vmID)
+	if err != nil {
+		return nil, diag.Diagnostics{
+			{
+				Severity: diag.Error,
+				Summary:  fmt.Sprintf("Error finding VM with ID %s", vmID),
+				Detail:   err.Error(),
+			},
+		}
+	}
+
+	// hot-plugging needs the PV drivers
+	if vm.PowerState == "Running" && vm.PVDriversDetected == false {
+		return nil, diag.Diagnostics{
+			{
+				Severity: diag.Error,
+				Summary:  fmt.Sprintf("Cannot change disks of VM %s while it is running without PV drivers", vmID),
+			},
+		}
+	}
+
+	return vm, nil
+}
+
+func resourceDiskAttachmentCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*providerMeta).client
+
+	vmID := d.Get("vm_id").(string)
+	vdiID := d.Get("disk_id").(string)
+
+	locks := m.(*providerMeta).locks
+	if err := locks.Lock(ctx, vmLockKey(vmID)); err != nil {
+		return lockDiags(vmLockKey(vmID), err)
+	}
+	defer locks.Unlock(vmLockKey(vmID))
+	if err := locks.Lock(ctx, vdiLockKey(vdiID)); err != nil {
+		return lockDiags(vdiLockKey(vdiID), err)
+	}
+	defer locks.Unlock(vdiLockKey(vdiID))
+
+	vm, diags := diskAttachmentVM(c, ctx, vmID)
+	if diags.HasError() {
+		return diags
+	}
+
+	vdi, err := c.GetVDIByID(ctx, vdiID)
+	if err != nil {
+		return diag.Diagnostics{
+			{
+				Severity: diag.Error,
+				Summary:  fmt.Sprintf("Error finding VDI with ID %s", vdiID),
+				Detail:   err.Error(),
+			},
+		}
+	}
+
+	if vdi.Pool != vm.Pool {
+		return diag.Diagnostics{
+			{
+				Severity: diag.Error,
+				Summary:  fmt.Sprintf("Disk (%s) is not in the same pool as the VM", vdi.ID),
+			},
+		}
+	}
+
+	mode := xo_client.VDIMode(d.Get("mode").(string))
+	vbd, err := vm.AttachDisk(c, ctx, vdi, mode, d.Get("bootable").(bool), d.Get("position").(string))
+	if err != nil {
+		return diag.Diagnostics{
+			{
+				Severity: diag.Error,
+				Summary:  fmt.Sprintf("Error attaching disk %s to VM", vdiID),
+				Detail:   err.Error(),
+			},
+		}
+	}
+
+	d.SetId(vbd.ID)
+
+	// the VBD is plugged right away on a running VM
+	if vm.PowerState == "Running" && vbd.Attached == false {
+		err := vbd.Connect(c, ctx)
+		if err != nil {
+			return diag.Diagnostics{
+				{
+					Severity: diag.Error,
+					Summary:  fmt.Sprintf("Error connecting vbd %s", vbd.ID),
+					Detail:   err.Error(),
+				},
+			}
+		}
+	}
+
+	return resourceDiskAttachmentRead(ctx, d, m)
+}
+
+func resourceDiskAttachmentRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*providerMeta).client
+
+	vbd, err := c.GetVBDByID(ctx, d.Id())
+	if err != nil {
+		if errors.Is(err, xo_client.NotFoundError) {
+			d.SetId("")
+			return nil
+		}
+
+		return diag.Diagnostics{
+			{
+				Severity: diag.Error,
+				Summary:  "Error getting disk attachment",
+				Detail:   err.Error(),
+			},
+		}
+	}
+
+	mode := xo_client.VDIModeRW
+	if vbd.ReadOnly {
+		mode = xo_client.VDIModeRO
+	}
+
+	d.Set("vm_id", vbd.VM)
+	d.Set("disk_id", vbd.VDI)
+	d.Set("mode", string(mode))
+	d.Set("bootable", vbd.Bootable)
+	d.Set("position", vbd.Position)
+	d.Set("device", vbd.Device)
+	d.Set("attached", vbd.Attached)
+
+	return nil
+}
+
+func resourceDiskAttachmentUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*providerMeta).client
+
+	vmID := d.Get("vm_id").(string)
+
+	locks := m.(*providerMeta).locks
+	if err := locks.Lock(ctx, vmLockKey(vmID)); err != nil {
+		return lockDiags(vmLockKey(vmID), err)
+	}
+	defer locks.Unlock(vmLockKey(vmID))
+
+	vbd, err := c.GetVBDByID(ctx, d.Id())
+	if err != nil {
+		return diag.Diagnostics{
+			{
+				Severity: diag.Error,
+				Summary:  "Error getting disk attachment",
+				Detail:   err.Error(),
+			},
+		}
+	}
+
+	if d.HasChange("bootable") {
+		err := vbd.SetBootable(c, ctx, d.Get("bootable").(bool))
+		if err != nil {
+			return diag.Diagnostics{
+				{
+					Severity: diag.Error,
+					Summary:  fmt.Sprintf("Error setting bootable of vbd %s", vbd.ID),
+					Detail:   err.Error(),
+				},
+			}
+		}
+	}
+
+	if d.Get("attached").(bool) && vbd.Attached == false {
+		_, diags := diskAttachmentVM(c, ctx, vmID)
+		if diags.HasError() {
+			return diags
+		}
+
+		err := vbd.Connect(c, ctx)
+		if err != nil {
+			return diag.Diagnostics{
+				{
+					Severity: diag.Error,
+					Summary:  fmt.Sprintf("Error connecting vbd %s", vbd.ID),
+					Detail:   err.Error(),
+				},
+			}
+		}
+	}
+
+	return resourceDiskAttachmentRead(ctx, d, m)
+}
+
+func resourceDiskAttachmentDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*providerMeta).client
+
+	vmID := d.Get("vm_id").(string)
+	vdiID := d.Get("disk_id").(string)
+
+	locks := m.(*providerMeta).locks
+	if err := locks.Lock(ctx, vmLockKey(vmID)); err != nil {
+		return lockDiags(vmLockKey(vmID), err)
+	}
+	defer locks.Unlock(vmLockKey(vmID))
+	if err := locks.Lock(ctx, vdiLockKey(vdiID)); err != nil {
+		return lockDiags(vdiLockKey(vdiID), err)
+	}
+	defer locks.Unlock(vdiLockKey(vdiID))
+
+	vbd, err := c.GetVBDByID(ctx, d.Id())
+	if err != nil {
+		if errors.Is(err, xo_client.NotFoundError) {
+			d.SetId("")
+			return nil
+		}
+
+		return diag.Diagnostics{
+			{
+				Severity: diag.Error,
+				Summary:  "Error getting disk attachment",
+				Detail:   err.Error(),
+			},
+		}
+	}
+
+	if vbd.Attached {
+		_, diags := diskAttachmentVM(c, ctx, vmID)
+		if diags.HasError() {
+			return diags
+		}
+
+		err := vbd.Disconnect(c, ctx)
+		if err != nil {
+			return diag.Diagnostics{
+				{
+					Severity: diag.Error,
+					Summary:  fmt.Sprintf("Error disconnecting vbd %s", vbd.ID),
+					Detail:   err.Error(),
+				},
+			}
+		}
+	}
+
+	err = vbd.Delete(c, ctx)
+	if err != nil {
+		return diag.Diagnostics{
+			{
+				Severity: diag.Error,
+				Summary:  fmt.Sprintf("Error deleting vbd %s", vbd.ID),
+				Detail:   err.Error(),
+			},
+		}
+	}
+
+	d.SetId("")
+
+	return nil
+}
@@ -0,0 +1,355 @@
+package xo
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+
+	"github.com/rmb938/terraform-provider-xenorchestra/xo_client/xotest"
+)
+
+const testAccDiskAttachment = "xenorchestra_disk_attachment.test"
+
+func (f *testAccFixtures) checkDiskAttachment(check func(vbd map[string]interface{}) error) resource.TestCheckFunc {
+	return testAccCheckInstance(testAccDiskAttachment, func(is *terraform.InstanceState) error {
+		vbd := f.server.Object(is.ID)
+		if vbd == nil {
+			return fmt.Errorf("VBD %s does not exist", is.ID)
+		}
+		return check(vbd)
+	})
+}
+
+func (f *testAccFixtures) checkDiskAttachmentDestroyed(s *terraform.State) error {
+	for _, rs := range s.RootModule().Resources {
+		if rs.Type != "xenorchestra_disk_attachment" {
+			continue
+		}
+		if f.server.Object(rs.Primary.ID) != nil {
+			return fmt.Errorf("VBD %s still exists", rs.Primary.ID)
+		}
+		if f.server.Object(rs.Primary.Attributes["disk_id"]) == nil {
+			return fmt.Errorf("VDI %s was deleted with its VBD", rs.Primary.Attributes["disk_id"])
+		}
+	}
+	return nil
+}
+
+// attachDisk attaches diskID to vmID outside of terraform and returns the VBD
+func (f *testAccFixtures) attachDisk(t *testing.T, vmID, diskID string) string {
+	t.Helper()
+
+	ctx := context.Background()
+	_, meta := f.testAccProvider(t)
+	c := meta.(*providerMeta).client
+
+	vm, err := c.GetVirtualMachineByID(ctx, vmID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vdi, err := c.GetVDIByID(ctx, diskID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vbd, err := vm.AttachDisk(c, ctx, vdi, "", false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return vbd.ID
+}
+
+func TestAccDiskAttachment_basic(t *testing.T) {
+	f := testAccSetup(t)
+
+	vmID := f.createVirtualMachine(t, "team-vm", nil, f.networkID)
+	diskID := f.server.AddVDI(f.storageRepositoryID, "data", 2*testAccGiB)
+
+	config := map[string]interface{}{
+		"vm_id":   vmID,
+		"disk_id": diskID,
+	}
+
+	var vbdID string
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy:      f.checkDiskAttachmentDestroyed,
+		Steps: []resource.TestStep{
+			{
+				Config: f.resourceConfig("xenorchestra_disk_attachment", config),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccDiskAttachment, "vm_id", vmID),
+					resource.TestCheckResourceAttr(testAccDiskAttachment, "disk_id", diskID),
+					resource.TestCheckResourceAttr(testAccDiskAttachment, "mode", "RW"),
+					resource.TestCheckResourceAttr(testAccDiskAttachment, "bootable", "false"),
+					resource.TestCheckResourceAttr(testAccDiskAttachment, "position", "1"),
+					resource.TestCheckResourceAttr(testAccDiskAttachment, "device", "xvdb"),
+					resource.TestCheckResourceAttr(testAccDiskAttachment, "attached", "false"),
+					testAccStoreID(testAccDiskAttachment, &vbdID),
+				),
+			},
+			{
+				Config: f.resourceConfig("xenorchestra_disk_attachment", withConfig(config, "bootable", true)),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccDiskAttachment, "bootable", "true"),
+					testAccCheckSameID(testAccDiskAttachment, &vbdID),
+					f.checkDiskAttachment(func(vbd map[string]interface{}) error {
+						if vbd["bootable"] != true {
+							return fmt.Errorf("VBD not updated: %v", vbd)
+						}
+						return nil
+					}),
+				),
+			},
+			{
+				// the disk is detached outside of terraform
+				PreConfig: func() {
+					ctx := context.Background()
+					_, meta := f.testAccProvider(t)
+					c := meta.(*providerMeta).client
+
+					vbd, err := c.GetVBDByID(ctx, vbdID)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if err := vbd.Delete(c, ctx); err != nil {
+						t.Fatal(err)
+					}
+				},
+				Config:             f.resourceConfig("xenorchestra_disk_attachment", withConfig(config, "bootable", true)),
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+			{
+				Config: f.resourceConfig("xenorchestra_disk_attachment", withConfig(config, "bootable", true)),
+				Check:  testAccCheckNewID(testAccDiskAttachment, &vbdID),
+			},
+		},
+	})
+}
+
+func TestAccDiskAttachment_running(t *testing.T) {
+	f := testAccSetup(t)
+
+	vmID := f.runningVirtualMachine(t, true, f.networkID)
+	diskID := f.server.AddVDI(f.storageRepositoryID, "data", 2*testAccGiB)
+
+	config := f.resourceConfig("xenorchestra_disk_attachment", map[string]interface{}{
+		"vm_id":    vmID,
+		"disk_id":  diskID,
+		"mode":     "RO",
+		"position": "3",
+	})
+
+	var vbdID string
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy:      f.checkDiskAttachmentDestroyed,
+		Steps: []resource.TestStep{
+			{
+				Config: config,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccDiskAttachment, "mode", "RO"),
+					resource.TestCheckResourceAttr(testAccDiskAttachment, "position", "3"),
+					resource.TestCheckResourceAttr(testAccDiskAttachment, "attached", "true"),
+					f.checkDiskAttachment(func(vbd map[string]interface{}) error {
+						if vbd["read_only"] != true || vbd["attached"] != true {
+							return fmt.Errorf("VBD not attached read only: %v", vbd)
+						}
+						return nil
+					}),
+					testAccStoreID(testAccDiskAttachment, &vbdID),
+				),
+			},
+			{
+				// the disk is unplugged outside of terraform
+				PreConfig: func() {
+					f.server.UpdateObject(vbdID, map[string]interface{}{"attached": false})
+				},
+				Config:             config,
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+			{
+				// and plugged back in
+				Config: config,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccDiskAttachment, "attached", "true"),
+					testAccCheckSameID(testAccDiskAttachment, &vbdID),
+				),
+			},
+		},
+	})
+}
+
+func TestAccDiskAttachment_withoutPVDrivers(t *testing.T) {
+	f := testAccSetup(t)
+
+	vmID := f.runningVirtualMachine(t, false, f.networkID)
+	diskID := f.server.AddVDI(f.storageRepositoryID, "data", 2*testAccGiB)
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: f.resourceConfig("xenorchestra_disk_attachment", map[string]interface{}{
+					"vm_id":   vmID,
+					"disk_id": diskID,
+				}),
+				ExpectError: regexp.MustCompile("running without PV drivers"),
+			},
+		},
+	})
+
+	if vbds := f.server.Object(diskID)["$VBDs"]; len(vbds.([]interface{})) != 0 {
+		t.Fatalf("expected disk not to be attached, got VBDs %v", vbds)
+	}
+}
+
+func TestAccDiskAttachment_import(t *testing.T) {
+	f := testAccSetup(t)
+
+	vmID := f.createVirtualMachine(t, "team-vm", nil, f.networkID)
+	diskID := f.server.AddVDI(f.storageRepositoryID, "data", 2*testAccGiB)
+	otherDiskID := f.server.AddVDI(f.storageRepositoryID, "other", 2*testAccGiB)
+	vbdID := f.attachDisk(t, vmID, otherDiskID)
+
+	config := f.resourceConfig("xenorchestra_disk_attachment", map[string]interface{}{
+		"vm_id":    vmID,
+		"disk_id":  diskID,
+		"mode":     "RO",
+		"bootable": true,
+	})
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy:      f.checkDiskAttachmentDestroyed,
+		Steps: []resource.TestStep{
+			{
+				Config: config,
+			},
+			{
+				ResourceName:      testAccDiskAttachment,
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+			{
+				// a disk attached outside of terraform
+				ResourceName:  testAccDiskAttachment,
+				ImportState:   true,
+				ImportStateId: vbdID,
+				ImportStateCheck: testAccCheckImportedAttrs(map[string]string{
+					"id":       vbdID,
+					"vm_id":    vmID,
+					"disk_id":  otherDiskID,
+					"position": "1",
+					"mode":     "RW",
+				}),
+			},
+		},
+	})
+}
+
+func TestAccDiskAttachment_alongsideVirtualMachine(t *testing.T) {
+	f := testAccSetup(t)
+	f.server.SetPVDriversDetected(true)
+
+	ownDisk := f.server.AddVDI(f.storageRepositoryID, "own", 2*testAccGiB)
+	otherDisk := f.server.AddVDI(f.storageRepositoryID, "other", 2*testAccGiB)
+	laterDisk := f.server.AddVDI(f.storageRepositoryID, "later", 2*testAccGiB)
+
+	var vmID, otherVBD string
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy:      f.checkDestroyed("xenorchestra_virtual_machine"),
+		Steps: []resource.TestStep{
+			{
+				Config: f.resourceConfig("xenorchestra_virtual_machine", f.virtualMachineConfig("test-vm", []string{ownDisk}, f.networkID)),
+				Check:  testAccStoreID(testAccVirtualMachine, &vmID),
+			},
+			{
+				// another team attaches a disk with xenorchestra_disk_attachment
+				PreConfig: func() {
+					otherVBD = f.attachDisk(t, vmID, otherDisk)
+				},
+				Config:   f.resourceConfig("xenorchestra_virtual_machine", f.virtualMachineConfig("test-vm", []string{ownDisk}, f.networkID)),
+				PlanOnly: true,
+			},
+			{
+				Config: f.resourceConfig("xenorchestra_virtual_machine", f.virtualMachineConfig("test-vm", []string{ownDisk, laterDisk}, f.networkID)),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "attached_disk.#", "2"),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "attached_disk.1.disk_id", laterDisk),
+					f.checkAttachedDisks(testAccVirtualMachine, ownDisk, otherDisk, laterDisk),
+				),
+			},
+			{
+				Config: f.resourceConfig("xenorchestra_virtual_machine", f.virtualMachineConfig("test-vm", nil, f.networkID)),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "attached_disk.#", "0"),
+					f.checkAttachedDisks(testAccVirtualMachine, otherDisk),
+					func(s *terraform.State) error {
+						if f.server.Object(otherVBD) == nil {
+							return fmt.Errorf("standalone VBD %s was removed", otherVBD)
+						}
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+func TestAccDiskAttachment_concurrent(t *testing.T) {
+	f := testAccSetup(t)
+
+	vmID := f.createVirtualMachine(t, "team-vm", nil, f.networkID)
+	firstDisk := f.server.AddVDI(f.storageRepositoryID, "first", 2*testAccGiB)
+	secondDisk := f.server.AddVDI(f.storageRepositoryID, "second", 2*testAccGiB)
+
+	// terraform applies both attachments at once, the slow attach keeps the other one waiting
+	f.server.InjectFault(xotest.Fault{Method: "vm.attachDisk", Times: 1, Delay: 500 * time.Millisecond})
+
+	config := f.config(
+		testAccBlock("resource", "xenorchestra_disk_attachment", "first", map[string]interface{}{
+			"vm_id":   vmID,
+			"disk_id": firstDisk,
+		}),
+		testAccBlock("resource", "xenorchestra_disk_attachment", "second", map[string]interface{}{
+			"vm_id":   vmID,
+			"disk_id": secondDisk,
+		}),
+	)
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy:      f.checkDiskAttachmentDestroyed,
+		Steps: []resource.TestStep{
+			{
+				Config: config,
+				Check: func(s *terraform.State) error {
+					positions := map[string]bool{}
+					for _, vbdID := range f.server.Object(vmID)["$VBDs"].([]interface{}) {
+						position := f.server.Object(vbdID.(string))["position"].(string)
+						if positions[position] {
+							return fmt.Errorf("two VBDs of VM %s at position %s", vmID, position)
+						}
+						positions[position] = true
+					}
+
+					first := s.RootModule().Resources["xenorchestra_disk_attachment.first"].Primary.Attributes["position"]
+					second := s.RootModule().Resources["xenorchestra_disk_attachment.second"].Primary.Attributes["position"]
+					if first == second {
+						return fmt.Errorf("both disks attached at position %s", first)
+					}
+					return nil
+				},
+			},
+		},
+	})
+}
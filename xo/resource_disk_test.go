@@ -0,0 +1,351 @@
+package xo
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+
+	"github.com/rmb938/terraform-provider-xenorchestra/xo_client/xotest"
+)
+
+const testAccDisk = "xenorchestra_disk.test"
+
+func (f *testAccFixtures) checkDisk(check func(vdi map[string]interface{}) error) resource.TestCheckFunc {
+	return testAccCheckInstance(testAccDisk, func(is *terraform.InstanceState) error {
+		vdi := f.server.Object(is.ID)
+		if vdi == nil {
+			return fmt.Errorf("VDI %s does not exist", is.ID)
+		}
+		return check(vdi)
+	})
+}
+
+func TestAccDisk_basic(t *testing.T) {
+	f := testAccSetup(t)
+
+	config := map[string]interface{}{
+		"name":                  "test-disk",
+		"description":           "a disk",
+		"storage_repository_id": f.storageRepositoryID,
+		"size":                  2,
+	}
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy:      f.checkDestroyed("xenorchestra_disk"),
+		Steps: []resource.TestStep{
+			{
+				Config: f.resourceConfig("xenorchestra_disk", config),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccDisk, "name", "test-disk"),
+					resource.TestCheckResourceAttr(testAccDisk, "description", "a disk"),
+					resource.TestCheckResourceAttr(testAccDisk, "size", "2"),
+					resource.TestCheckResourceAttr(testAccDisk, "mode", "RW"),
+					resource.TestCheckResourceAttr(testAccDisk, "storage_repository_id", f.storageRepositoryID),
+				),
+			},
+			{
+				Config: f.resourceConfig("xenorchestra_disk", map[string]interface{}{
+					"name":                  "renamed-disk",
+					"description":           "still a disk",
+					"storage_repository_id": f.storageRepositoryID,
+					"size":                  4,
+				}),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccDisk, "name", "renamed-disk"),
+					resource.TestCheckResourceAttr(testAccDisk, "description", "still a disk"),
+					resource.TestCheckResourceAttr(testAccDisk, "size", "4"),
+					f.checkDisk(func(vdi map[string]interface{}) error {
+						if vdi["name_label"] != "renamed-disk" || vdi["name_description"] != "still a disk" || vdi["size"] != float64(4*testAccGiB) {
+							return fmt.Errorf("VDI not updated: %v", vdi)
+						}
+						return nil
+					}),
+				),
+			},
+		},
+	})
+}
+
+func TestAccDisk_shrink(t *testing.T) {
+	f := testAccSetup(t)
+
+	config := map[string]interface{}{
+		"name":                  "test-disk",
+		"storage_repository_id": f.storageRepositoryID,
+		"size":                  4,
+	}
+
+	var diskID string
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy:      f.checkDestroyed("xenorchestra_disk"),
+		Steps: []resource.TestStep{
+			{
+				Config: f.resourceConfig("xenorchestra_disk", config),
+				Check:  testAccStoreID(testAccDisk, &diskID),
+			},
+			{
+				// disks can't shrink so they are replaced
+				Config: f.resourceConfig("xenorchestra_disk", withConfig(config, "size", 2)),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccDisk, "size", "2"),
+					testAccCheckNewID(testAccDisk, &diskID),
+					func(s *terraform.State) error {
+						if f.server.Object(diskID) != nil {
+							return fmt.Errorf("replaced disk %s still exists", diskID)
+						}
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+func TestAccDisk_drift(t *testing.T) {
+	f := testAccSetup(t)
+
+	config := f.resourceConfig("xenorchestra_disk", map[string]interface{}{
+		"name":                  "test-disk",
+		"storage_repository_id": f.storageRepositoryID,
+		"size":                  2,
+	})
+
+	var diskID string
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy:      f.checkDestroyed("xenorchestra_disk"),
+		Steps: []resource.TestStep{
+			{
+				Config: config,
+				Check:  testAccStoreID(testAccDisk, &diskID),
+			},
+			{
+				PreConfig: func() {
+					f.server.UpdateObject(diskID, map[string]interface{}{"name_label": "renamed-out-of-band"})
+				},
+				Config:             config,
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+			{
+				Config: config,
+				Check:  resource.TestCheckResourceAttr(testAccDisk, "name", "test-disk"),
+			},
+			{
+				PreConfig: func() {
+					f.server.DeleteObject(diskID)
+				},
+				Config:             config,
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+			{
+				Config: config,
+				Check:  testAccCheckNewID(testAccDisk, &diskID),
+			},
+		},
+	})
+}
+
+func TestAccDisk_modeDrift(t *testing.T) {
+	f := testAccSetup(t)
+
+	config := f.resourceConfig("xenorchestra_disk", map[string]interface{}{
+		"name":                  "test-disk",
+		"storage_repository_id": f.storageRepositoryID,
+		"size":                  2,
+		"mode":                  "RO",
+	})
+
+	var diskID, vbdID string
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy:      f.checkDestroyed("xenorchestra_disk"),
+		Steps: []resource.TestStep{
+			{
+				// a disk that isn't attached keeps its mode
+				Config: config,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccDisk, "mode", "RO"),
+					testAccStoreID(testAccDisk, &diskID),
+				),
+			},
+			{
+				// the disk is attached read-write outside of terraform
+				PreConfig: func() {
+					f.createVirtualMachine(t, "legacy-vm", []string{diskID}, f.networkID)
+					vbdID = f.server.Object(diskID)["$VBDs"].([]interface{})[0].(string)
+				},
+				Config:             config,
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+			{
+				PreConfig: func() {
+					f.server.UpdateObject(vbdID, map[string]interface{}{"read_only": true})
+				},
+				Config:   config,
+				PlanOnly: true,
+			},
+		},
+	})
+}
+
+func TestAccDisk_timeout(t *testing.T) {
+	f := testAccSetup(t)
+
+	f.server.InjectFault(xotest.Fault{Method: "disk.create", Times: 1, Delay: time.Second})
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: f.resourceConfig("xenorchestra_disk", map[string]interface{}{
+					"name":                  "test-disk",
+					"storage_repository_id": f.storageRepositoryID,
+					"size":                  2,
+					"timeouts": map[string]interface{}{
+						"create": "100ms",
+					},
+				}),
+				ExpectError: regexp.MustCompile("disk.create was still pending"),
+			},
+		},
+	})
+}
+
+func TestAccDisk_import(t *testing.T) {
+	f := testAccSetup(t)
+
+	config := f.resourceConfig("xenorchestra_disk", map[string]interface{}{
+		"name":                  "data",
+		"description":           "created by terraform",
+		"storage_repository_id": f.storageRepositoryID,
+		"size":                  2,
+	})
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy:      f.checkDestroyed("xenorchestra_disk"),
+		Steps: []resource.TestStep{
+			{
+				Config: config,
+			},
+			{
+				ResourceName:      testAccDisk,
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+			{
+				ResourceName:      testAccDisk,
+				ImportState:       true,
+				ImportStateId:     "Local storage/data",
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
+func TestAccDisk_importExisting(t *testing.T) {
+	f := testAccSetup(t)
+
+	diskID := f.server.AddVDI(f.storageRepositoryID, "data", 2*testAccGiB)
+	f.server.UpdateObject(diskID, map[string]interface{}{"name_description": "predates terraform"})
+
+	readOnlyID := f.server.AddVDI(f.storageRepositoryID, "read-only", 2*testAccGiB)
+	f.createVirtualMachine(t, "legacy-vm", []string{readOnlyID}, f.networkID)
+
+	// the disk is attached read only
+	vbdID := f.server.Object(readOnlyID)["$VBDs"].([]interface{})[0].(string)
+	f.server.UpdateObject(vbdID, map[string]interface{}{"read_only": true})
+
+	config := f.resourceConfig("xenorchestra_disk", map[string]interface{}{
+		"name":                  "data",
+		"storage_repository_id": f.storageRepositoryID,
+		"size":                  2,
+	})
+
+	checkImported := func(id, mode string) resource.ImportStateCheckFunc {
+		return func(states []*terraform.InstanceState) error {
+			if len(states) != 1 {
+				return fmt.Errorf("expected 1 imported disk, got %d", len(states))
+			}
+			if states[0].ID != id || states[0].Attributes["mode"] != mode {
+				return fmt.Errorf("expected disk %s in mode %s, got %s in mode %s", id, mode, states[0].ID, states[0].Attributes["mode"])
+			}
+			return nil
+		}
+	}
+
+	steps := []resource.TestStep{
+		{
+			ResourceName:     testAccDisk,
+			Config:           config,
+			ImportState:      true,
+			ImportStateId:    diskID,
+			ImportStateCheck: checkImported(diskID, "RW"),
+		},
+		{
+			ResourceName:     testAccDisk,
+			Config:           config,
+			ImportState:      true,
+			ImportStateId:    "Local storage/data",
+			ImportStateCheck: checkImported(diskID, "RW"),
+		},
+		{
+			ResourceName:     testAccDisk,
+			Config:           config,
+			ImportState:      true,
+			ImportStateId:    readOnlyID,
+			ImportStateCheck: checkImported(readOnlyID, "RO"),
+		},
+	}
+
+	for _, id := range []string{"missing", "Local storage/missing", "missing/data"} {
+		steps = append(steps, resource.TestStep{
+			ResourceName:  testAccDisk,
+			Config:        config,
+			ImportState:   true,
+			ImportStateId: id,
+			ExpectError:   regexp.MustCompile("Resource Not Found"),
+		})
+	}
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		Steps:             steps,
+	})
+}
+
+func TestAccDisk_importAmbiguousStorageRepository(t *testing.T) {
+	f := testAccSetup(t)
+
+	f.server.AddVDI(f.storageRepositoryID, "data", 2*testAccGiB)
+
+	// every pool has its own Local storage
+	otherPool := f.server.AddPool("other-pool")
+	f.server.AddStorageRepository(otherPool, "Local storage", "ext")
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				ResourceName: testAccDisk,
+				Config: f.resourceConfig("xenorchestra_disk", map[string]interface{}{
+					"name":                  "data",
+					"storage_repository_id": f.storageRepositoryID,
+					"size":                  2,
+				}),
+				ImportState:   true,
+				ImportStateId: "Local storage/data",
+				ExpectError:   regexp.MustCompile("storage repository name Local storage is ambiguous"),
+			},
+		},
+	})
+}
@@ -0,0 +1,400 @@
+package xo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/rmb938/terraform-provider-xenorchestra/xo_client"
+)
+
+// resourceNetworkInterface is a VIF managed on its own, the network_interface list of the
+// VM's xenorchestra_virtual_machine leaves it alone
+func resourceNetworkInterface() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: resourceNetworkInterfaceCreate,
+		ReadContext:   resourceNetworkInterfaceRead,
+		UpdateContext: resourceNetworkInterfaceUpdate,
+		DeleteContext: resourceNetworkInterfaceDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(10 * time.Minute),
+			Update: schema.DefaultTimeout(10 * time.Minute),
+			Delete: schema.DefaultTimeout(10 * time.Minute),
+		},
+		Schema: map[string]*schema.Schema{
+			"vm_id": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			// the VIF is recreated on the same device with the same MAC when the network changes
+			"network_id": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+			"device": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Computed: true,
+				ForceNew: true,
+			},
+			"mac_address": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validateMACAddress,
+				DiffSuppressFunc: func(k, old, new string, d *schema.ResourceData) bool {
+					return strings.EqualFold(old, new)
+				},
+			},
+			"attached": {
+				Type:     schema.TypeBool,
+				Computed: true,
+			},
+		},
+		CustomizeDiff: customizeNetworkInterfaceAttached,
+	}
+}
+
+// customizeNetworkInterfaceAttached plans plugging the VIF back in when it was unplugged while
+// the VM is running
+func customizeNetworkInterfaceAttached(ctx context.Context, diff *schema.ResourceDiff, m interface{}) error {
+	if len(diff.Id()) == 0 || diff.Get("attached").(bool) {
+		return nil
+	}
+
+	c := m.(*providerMeta).client
+
+	vm, err := c.GetVirtualMachineByID(ctx, diff.Get("vm_id").(string))
+	if err != nil {
+		if errors.Is(err, xo_client.NotFoundError) {
+			return nil
+		}
+		return err
+	}
+
+	if vm.PowerState == "Running" {
+		return diff.SetNew("attached", true)
+	}
+
+	return nil
+}
+
+// networkInterfaceVM gets the VM of the network interface and checks that a VIF can be
+// plugged into or unplugged from it
+func networkInterfaceVM(c *xo_client.Client, ctx context.Context, vmID string) (*xo_client.VirtualMachine, diag.Diagnostics) {
+	vm, err := c.GetVirtualMachineByID(ctx, vmID)
+	if err != nil {
+		return nil, diag.Diagnostics{
+			{
+				Severity: diag.Error,
+				Summary:  fmt.Sprintf("Error finding VM with ID %s", vmID),
+				Detail:   err.Error(),
+			},
+		}
+	}
+
+	// hot-plugging needs the PV drivers
+	if vm.PowerState == "Running" && vm.PVDriversDetected == false {
+		return nil, diag.Diagnostics{
+			{
+				Severity: diag.Error,
+				Summary:  fmt.Sprintf("Cannot change network interfaces of VM %s while it is running without PV drivers", vmID),
+			},
+		}
+	}
+
+	return vm, nil
+}
+
+// networkInterfaceNetwork gets the network of the network interface and checks that it can be
+// attached to vm
+func networkInterfaceNetwork(c *xo_client.Client, ctx context.Context, d *schema.ResourceData, vm *xo_client.VirtualMachine) (*xo_client.Network, diag.Diagnostics) {
+	networkID := d.Get("network_id").(string)
+
+	network, err := c.GetNetworkByID(ctx, networkID)
+	if err != nil {
+		return nil, diag.Diagnostics{
+			{
+				Severity: diag.Error,
+				Summary:  fmt.Sprintf("Error getting Network %s", networkID),
+				Detail:   err.Error(),
+			},
+		}
+	}
+
+	if network.Pool != vm.Pool {
+		return nil, diag.Diagnostics{
+			{
+				Severity: diag.Error,
+				Summary:  fmt.Sprintf("Network (%s) is not in the same pool as the VM", network.ID),
+			},
+		}
+	}
+
+	return network, nil
+}
+
+// createNetworkInterface creates the VIF of the network interface on vm, on the device of the
+// network interface when it has one, and plugs it when vm is running
+func createNetworkInterface(c *xo_client.Client, ctx context.Context, d *schema.ResourceData, vm *xo_client.VirtualMachine, network *xo_client.Network) diag.Diagnostics {
+	device := d.Get("device").(string)
+
+	vif, err := vm.AttachNetwork(c, ctx, network, device, d.Get("mac_address").(string), 0)
+	if err != nil {
+		return diag.Diagnostics{
+			{
+				Severity: diag.Error,
+				Summary:  fmt.Sprintf("Error creating VIF with network %s", network.ID),
+				Detail:   err.Error(),
+			},
+		}
+	}
+
+	d.SetId(vif.ID)
+
+	if len(device) > 0 && vif.Device != device {
+		return diag.Diagnostics{
+			{
+				Severity: diag.Error,
+				Summary:  fmt.Sprintf("VIF %s was created on device %s instead of %s", vif.ID, vif.Device, device),
+			},
+		}
+	}
+
+	if vm.PowerState == "Running" && vif.Attached == false {
+		err := vif.Connect(c, ctx)
+		if err != nil {
+			return diag.Diagnostics{
+				{
+					Severity: diag.Error,
+					Summary:  fmt.Sprintf("Error connecting vif %s", vif.ID),
+					Detail:   err.Error(),
+				},
+			}
+		}
+	}
+
+	return nil
+}
+
+// deleteNetworkInterface unplugs the VIF when it is attached and deletes it
+func deleteNetworkInterface(c *xo_client.Client, ctx context.Context, vif *xo_client.VIF) diag.Diagnostics {
+	if vif.Attached {
+		err := vif.Disconnect(c, ctx)
+		if err != nil {
+			return diag.Diagnostics{
+				{
+					Severity: diag.Error,
+					Summary:  fmt.Sprintf("Error disconnecting vif %s", vif.ID),
+					Detail:   err.Error(),
+				},
+			}
+		}
+	}
+
+	err := vif.Delete(c, ctx)
+	if err != nil {
+		return diag.Diagnostics{
+			{
+				Severity: diag.Error,
+				Summary:  fmt.Sprintf("Error deleting vif %s", vif.ID),
+				Detail:   err.Error(),
+			},
+		}
+	}
+
+	return nil
+}
+
+func resourceNetworkInterfaceCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*providerMeta).client
+
+	vmID := d.Get("vm_id").(string)
+
+	locks := m.(*providerMeta).locks
+	if err := locks.Lock(ctx, vmLockKey(vmID)); err != nil {
+		return lockDiags(vmLockKey(vmID), err)
+	}
+	defer locks.Unlock(vmLockKey(vmID))
+
+	vm, diags := networkInterfaceVM(c, ctx, vmID)
+	if diags.HasError() {
+		return diags
+	}
+
+	network, diags := networkInterfaceNetwork(c, ctx, d, vm)
+	if diags.HasError() {
+		return diags
+	}
+
+	diags = createNetworkInterface(c, ctx, d, vm, network)
+	if diags.HasError() {
+		return diags
+	}
+
+	return resourceNetworkInterfaceRead(ctx, d, m)
+}
+
+func resourceNetworkInterfaceRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*providerMeta).client
+
+	vif, err := c.GetVIFByID(ctx, d.Id())
+	if err != nil {
+		if errors.Is(err, xo_client.NotFoundError) {
+			d.SetId("")
+			return nil
+		}
+
+		return diag.Diagnostics{
+			{
+				Severity: diag.Error,
+				Summary:  "Error getting network interface",
+				Detail:   err.Error(),
+			},
+		}
+	}
+
+	d.Set("vm_id", vif.VM)
+	d.Set("network_id", vif.NetworkID)
+	d.Set("device", vif.Device)
+	d.Set("mac_address", vif.MAC)
+	d.Set("attached", vif.Attached)
+
+	return nil
+}
+
+func resourceNetworkInterfaceUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*providerMeta).client
+
+	vmID := d.Get("vm_id").(string)
+
+	locks := m.(*providerMeta).locks
+	if err := locks.Lock(ctx, vmLockKey(vmID)); err != nil {
+		return lockDiags(vmLockKey(vmID), err)
+	}
+	defer locks.Unlock(vmLockKey(vmID))
+
+	if d.HasChanges("network_id", "mac_address") {
+		vif, err := c.GetVIFByID(ctx, d.Id())
+		if err != nil {
+			return diag.Diagnostics{
+				{
+					Severity: diag.Error,
+					Summary:  "Error getting network interface",
+					Detail:   err.Error(),
+				},
+			}
+		}
+
+		vm, diags := networkInterfaceVM(c, ctx, vmID)
+		if diags.HasError() {
+			return diags
+		}
+
+		// checked before the old VIF is gone
+		network, diags := networkInterfaceNetwork(c, ctx, d, vm)
+		if diags.HasError() {
+			return diags
+		}
+
+		// the VIF is recreated in place, device and MAC are carried over from the state
+		// unless they are changed
+		diags = deleteNetworkInterface(c, ctx, vif)
+		if diags.HasError() {
+			return diags
+		}
+
+		diags = createNetworkInterface(c, ctx, d, vm, network)
+		if diags.HasError() {
+			// the old VIF is gone, without a new one the network interface has to be created again
+			if d.Id() == vif.ID {
+				d.SetId("")
+			}
+			return diags
+		}
+	} else if d.Get("attached").(bool) {
+		vif, err := c.GetVIFByID(ctx, d.Id())
+		if err != nil {
+			return diag.Diagnostics{
+				{
+					Severity: diag.Error,
+					Summary:  "Error getting network interface",
+					Detail:   err.Error(),
+				},
+			}
+		}
+
+		if vif.Attached == false {
+			_, diags := networkInterfaceVM(c, ctx, vmID)
+			if diags.HasError() {
+				return diags
+			}
+
+			err := vif.Connect(c, ctx)
+			if err != nil {
+				return diag.Diagnostics{
+					{
+						Severity: diag.Error,
+						Summary:  fmt.Sprintf("Error connecting vif %s", vif.ID),
+						Detail:   err.Error(),
+					},
+				}
+			}
+		}
+	}
+
+	return resourceNetworkInterfaceRead(ctx, d, m)
+}
+
+func resourceNetworkInterfaceDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*providerMeta).client
+
+	vmID := d.Get("vm_id").(string)
+
+	locks := m.(*providerMeta).locks
+	if err := locks.Lock(ctx, vmLockKey(vmID)); err != nil {
+		return lockDiags(vmLockKey(vmID), err)
+	}
+	defer locks.Unlock(vmLockKey(vmID))
+
+	vif, err := c.GetVIFByID(ctx, d.Id())
+	if err != nil {
+		if errors.Is(err, xo_client.NotFoundError) {
+			d.SetId("")
+			return nil
+		}
+
+		return diag.Diagnostics{
+			{
+				Severity: diag.Error,
+				Summary:  "Error getting network interface",
+				Detail:   err.Error(),
+			},
+		}
+	}
+
+	if vif.Attached {
+		_, diags := networkInterfaceVM(c, ctx, vmID)
+		if diags.HasError() {
+			return diags
+		}
+	}
+
+	diags := deleteNetworkInterface(c, ctx, vif)
+	if diags.HasError() {
+		return diags
+	}
+
+	d.SetId("")
+
+	return nil
+}
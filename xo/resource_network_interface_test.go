@@ -0,0 +1,410 @@
+package xo
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+
+	"github.com/rmb938/terraform-provider-xenorchestra/xo_client/xotest"
+)
+
+const testAccNetworkInterface = "xenorchestra_network_interface.test"
+
+func (f *testAccFixtures) checkNetworkInterface(check func(vif map[string]interface{}) error) resource.TestCheckFunc {
+	return testAccCheckInstance(testAccNetworkInterface, func(is *terraform.InstanceState) error {
+		vif := f.server.Object(is.ID)
+		if vif == nil {
+			return fmt.Errorf("VIF %s does not exist", is.ID)
+		}
+		return check(vif)
+	})
+}
+
+// checkVirtualMachineNetworks checks the networks of the VIFs on vmID
+func (f *testAccFixtures) checkVirtualMachineNetworks(vmID string, networkIDs ...string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		return f.checkNetworksOf(vmID, networkIDs...)
+	}
+}
+
+// runningVirtualMachine creates a running VM outside of terraform
+func (f *testAccFixtures) runningVirtualMachine(t *testing.T, pvDrivers bool, networkIDs ...string) string {
+	t.Helper()
+
+	vmID := f.createVirtualMachine(t, "team-vm", nil, networkIDs...)
+	f.server.UpdateObject(vmID, map[string]interface{}{"power_state": "Running", "pvDriversDetected": pvDrivers})
+
+	return vmID
+}
+
+// attachNetwork adds a VIF on networkID to vmID outside of terraform and returns it
+func (f *testAccFixtures) attachNetwork(t *testing.T, vmID, networkID string) string {
+	t.Helper()
+
+	ctx := context.Background()
+	_, meta := f.testAccProvider(t)
+	c := meta.(*providerMeta).client
+
+	vm, err := c.GetVirtualMachineByID(ctx, vmID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	network, err := c.GetNetworkByID(ctx, networkID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vif, err := vm.AttachNetwork(c, ctx, network, "", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return vif.ID
+}
+
+func TestAccNetworkInterface_basic(t *testing.T) {
+	f := testAccSetup(t)
+
+	vmID := f.runningVirtualMachine(t, true, f.networkID)
+
+	config := map[string]interface{}{
+		"vm_id":      vmID,
+		"network_id": f.otherNetworkID,
+	}
+
+	var vifID, mac string
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy: resource.ComposeTestCheckFunc(
+			f.checkDestroyed("xenorchestra_network_interface"),
+			f.checkVirtualMachineNetworks(vmID, f.networkID),
+		),
+		Steps: []resource.TestStep{
+			{
+				Config: f.resourceConfig("xenorchestra_network_interface", config),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccNetworkInterface, "vm_id", vmID),
+					resource.TestCheckResourceAttr(testAccNetworkInterface, "network_id", f.otherNetworkID),
+					resource.TestCheckResourceAttr(testAccNetworkInterface, "device", "1"),
+					resource.TestCheckResourceAttr(testAccNetworkInterface, "attached", "true"),
+					resource.TestCheckResourceAttrSet(testAccNetworkInterface, "mac_address"),
+					f.checkVirtualMachineNetworks(vmID, f.otherNetworkID, f.networkID),
+					testAccCheckInstance(testAccNetworkInterface, func(is *terraform.InstanceState) error {
+						vifID = is.ID
+						mac = is.Attributes["mac_address"]
+						return nil
+					}),
+				),
+			},
+			{
+				Config: f.resourceConfig("xenorchestra_network_interface", withConfig(config, "network_id", f.networkID)),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccNetworkInterface, "network_id", f.networkID),
+					resource.TestCheckResourceAttr(testAccNetworkInterface, "device", "1"),
+					f.checkVirtualMachineNetworks(vmID, f.networkID, f.networkID),
+					testAccCheckNewID(testAccNetworkInterface, &vifID),
+					testAccCheckInstance(testAccNetworkInterface, func(is *terraform.InstanceState) error {
+						if is.Attributes["mac_address"] != mac {
+							return fmt.Errorf("expected MAC %s to be kept, got %s", mac, is.Attributes["mac_address"])
+						}
+						return nil
+					}),
+				),
+			},
+			{
+				Config: f.resourceConfig("xenorchestra_network_interface", withConfig(withConfig(config, "network_id", f.networkID), "mac_address", "02:00:00:aa:bb:cc")),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccNetworkInterface, "mac_address", "02:00:00:aa:bb:cc"),
+					f.checkNetworkInterface(func(vif map[string]interface{}) error {
+						if vif["MAC"] != "02:00:00:aa:bb:cc" || vif["device"] != "1" {
+							return fmt.Errorf("VIF not updated: %v", vif)
+						}
+						return nil
+					}),
+				),
+			},
+		},
+	})
+}
+
+func TestAccNetworkInterface_pluggedBackIn(t *testing.T) {
+	f := testAccSetup(t)
+
+	vmID := f.runningVirtualMachine(t, true, f.networkID)
+
+	config := f.resourceConfig("xenorchestra_network_interface", map[string]interface{}{
+		"vm_id":      vmID,
+		"network_id": f.otherNetworkID,
+	})
+
+	var vifID string
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy:      f.checkDestroyed("xenorchestra_network_interface"),
+		Steps: []resource.TestStep{
+			{
+				Config: config,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccNetworkInterface, "attached", "true"),
+					testAccStoreID(testAccNetworkInterface, &vifID),
+				),
+			},
+			{
+				// the VIF is unplugged outside of terraform
+				PreConfig: func() {
+					f.server.UpdateObject(vifID, map[string]interface{}{"attached": false})
+				},
+				Config:             config,
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+			{
+				// and plugged back in
+				Config: config,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccNetworkInterface, "attached", "true"),
+					testAccCheckSameID(testAccNetworkInterface, &vifID),
+					f.checkNetworkInterface(func(vif map[string]interface{}) error {
+						if vif["attached"] != true {
+							return fmt.Errorf("VIF not plugged back in: %v", vif)
+						}
+						return nil
+					}),
+					func(s *terraform.State) error {
+						if calls := len(f.server.Calls("vif.connect")); calls != 1 {
+							return fmt.Errorf("expected 1 vif.connect call, got %d", calls)
+						}
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+func TestAccNetworkInterface_timeout(t *testing.T) {
+	f := testAccSetup(t)
+
+	vmID := f.createVirtualMachine(t, "team-vm", nil, f.networkID)
+
+	f.server.InjectFault(xotest.Fault{Method: "vm.createInterface", Times: 1, Delay: time.Second})
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: f.resourceConfig("xenorchestra_network_interface", map[string]interface{}{
+					"vm_id":      vmID,
+					"network_id": f.otherNetworkID,
+					"timeouts": map[string]interface{}{
+						"create": "100ms",
+					},
+				}),
+				ExpectError: regexp.MustCompile("vm.createInterface was still pending"),
+			},
+		},
+	})
+}
+
+func TestAccNetworkInterface_device(t *testing.T) {
+	f := testAccSetup(t)
+
+	vmID := f.createVirtualMachine(t, "team-vm", nil, f.networkID)
+
+	config := map[string]interface{}{
+		"vm_id":      vmID,
+		"network_id": f.otherNetworkID,
+		"device":     "3",
+	}
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy:      f.checkDestroyed("xenorchestra_network_interface"),
+		Steps: []resource.TestStep{
+			{
+				Config: f.resourceConfig("xenorchestra_network_interface", config),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccNetworkInterface, "device", "3"),
+					resource.TestCheckResourceAttr(testAccNetworkInterface, "attached", "false"),
+				),
+			},
+			{
+				Config:      f.resourceConfig("xenorchestra_network_interface", withConfig(config, "device", "0")),
+				ExpectError: regexp.MustCompile("DEVICE_ALREADY_EXISTS"),
+			},
+		},
+	})
+}
+
+func TestAccNetworkInterface_withoutPVDrivers(t *testing.T) {
+	f := testAccSetup(t)
+
+	vmID := f.runningVirtualMachine(t, false, f.networkID)
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: f.resourceConfig("xenorchestra_network_interface", map[string]interface{}{
+					"vm_id":      vmID,
+					"network_id": f.otherNetworkID,
+				}),
+				ExpectError: regexp.MustCompile("running without PV drivers"),
+			},
+		},
+	})
+
+	if err := f.checkNetworksOf(vmID, f.networkID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAccNetworkInterface_import(t *testing.T) {
+	f := testAccSetup(t)
+
+	vmID := f.createVirtualMachine(t, "team-vm", nil, f.networkID)
+	vifID := f.server.Object(vmID)["VIFs"].([]interface{})[0].(string)
+	mac := f.server.Object(vifID)["MAC"].(string)
+
+	config := f.resourceConfig("xenorchestra_network_interface", map[string]interface{}{
+		"vm_id":      vmID,
+		"network_id": f.otherNetworkID,
+	})
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy:      f.checkDestroyed("xenorchestra_network_interface"),
+		Steps: []resource.TestStep{
+			{
+				Config: config,
+			},
+			{
+				ResourceName:      testAccNetworkInterface,
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+			{
+				// the VIF the VM was created with
+				ResourceName:  testAccNetworkInterface,
+				ImportState:   true,
+				ImportStateId: vifID,
+				ImportStateCheck: testAccCheckImportedAttrs(map[string]string{
+					"id":          vifID,
+					"vm_id":       vmID,
+					"network_id":  f.networkID,
+					"device":      "0",
+					"mac_address": mac,
+				}),
+			},
+		},
+	})
+}
+
+func TestAccNetworkInterface_alongsideVirtualMachine(t *testing.T) {
+	f := testAccSetup(t)
+	f.server.SetPVDriversDetected(true)
+
+	config := f.resourceConfig("xenorchestra_virtual_machine", f.virtualMachineConfig("test-vm", nil, f.networkID))
+
+	var vmID, standaloneID string
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy:      f.checkDestroyed("xenorchestra_virtual_machine"),
+		Steps: []resource.TestStep{
+			{
+				Config: config,
+				Check:  testAccStoreID(testAccVirtualMachine, &vmID),
+			},
+			{
+				// another team adds a NIC with xenorchestra_network_interface
+				PreConfig: func() {
+					standaloneID = f.attachNetwork(t, vmID, f.otherNetworkID)
+				},
+				Config:   config,
+				PlanOnly: true,
+			},
+			{
+				Config: f.resourceConfig("xenorchestra_virtual_machine", f.virtualMachineConfig("test-vm", nil, f.networkID, f.networkID)),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "network_interface.#", "2"),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "network_interface.1.device", "2"),
+					f.checkNetworks(testAccVirtualMachine, f.networkID, f.otherNetworkID, f.networkID),
+				),
+			},
+			{
+				Config: config,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "network_interface.#", "1"),
+					f.checkNetworks(testAccVirtualMachine, f.otherNetworkID, f.networkID),
+					func(s *terraform.State) error {
+						if f.server.Object(standaloneID) == nil {
+							return fmt.Errorf("standalone VIF %s was removed", standaloneID)
+						}
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+func TestAccNetworkInterface_recreate(t *testing.T) {
+	f := testAccSetup(t)
+
+	vmID := f.createVirtualMachine(t, "team-vm", nil, f.networkID)
+	foreignNetworkID := f.server.AddNetwork(f.server.AddPool("other-pool"), "Pool-wide network")
+
+	config := map[string]interface{}{
+		"vm_id":      vmID,
+		"network_id": f.otherNetworkID,
+	}
+
+	var vifID string
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy:      f.checkDestroyed("xenorchestra_network_interface"),
+		Steps: []resource.TestStep{
+			{
+				Config: f.resourceConfig("xenorchestra_network_interface", config),
+				Check:  testAccStoreID(testAccNetworkInterface, &vifID),
+			},
+			{
+				Config:      f.resourceConfig("xenorchestra_network_interface", withConfig(config, "network_id", foreignNetworkID)),
+				ExpectError: regexp.MustCompile("is not in the same pool as the VM"),
+			},
+			{
+				// the VIF is only deleted once the new network is known to be usable
+				Config: f.resourceConfig("xenorchestra_network_interface", config),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckSameID(testAccNetworkInterface, &vifID),
+					f.checkVirtualMachineNetworks(vmID, f.otherNetworkID, f.networkID),
+				),
+			},
+			{
+				PreConfig: func() {
+					f.server.InjectFault(xotest.Fault{Method: "vm.createInterface", Times: 1, Err: xotest.XapiError("INTERNAL_ERROR")})
+				},
+				Config:      f.resourceConfig("xenorchestra_network_interface", withConfig(config, "network_id", f.networkID)),
+				ExpectError: regexp.MustCompile("INTERNAL_ERROR"),
+			},
+			{
+				// the old VIF is gone and no new one took its place
+				Config:             f.resourceConfig("xenorchestra_network_interface", withConfig(config, "network_id", f.networkID)),
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+			{
+				Config: f.resourceConfig("xenorchestra_network_interface", withConfig(config, "network_id", f.networkID)),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckNewID(testAccNetworkInterface, &vifID),
+					resource.TestCheckResourceAttr(testAccNetworkInterface, "device", "1"),
+					f.checkVirtualMachineNetworks(vmID, f.networkID, f.networkID),
+				),
+			},
+		},
+	})
+}
@@ -2,7 +2,12 @@ package xo
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
@@ -19,6 +24,15 @@ func resourceVirtualMachine() *schema.Resource {
 		ReadContext:   resourceVirtualMachineRead,
 		UpdateContext: resourceVirtualMachineUpdate,
 		DeleteContext: resourceVirtualMachineDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceVirtualMachineImport,
+		},
+		// copying a template and waiting for the VM to boot can take a long time on slow storage
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(30 * time.Minute),
+			Update: schema.DefaultTimeout(30 * time.Minute),
+			Delete: schema.DefaultTimeout(10 * time.Minute),
+		},
 		Schema: map[string]*schema.Schema{
 			"name": {
 				Type:     schema.TypeString,
@@ -28,39 +42,71 @@ func resourceVirtualMachine() *schema.Resource {
 				Type:     schema.TypeString,
 				Optional: true,
 			},
+			// replacing on template_id and installation changes is done in CustomizeDiff
 			"template_id": {
 				Type:     schema.TypeString,
 				Required: true,
-				ForceNew: true,
 			},
 			"cpus": {
-				Type:     schema.TypeInt,
-				Required: true,
-				ForceNew: true,
+				Type:         schema.TypeInt,
+				Required:     true,
+				ValidateFunc: validation.IntAtLeast(1),
+			},
+			// vCPUs can be hot added up to max_cpus while the VM is running, it is only tracked when
+			// configured, otherwise the max of the VM is raised along with cpus
+			"max_cpus": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ValidateFunc: validation.IntAtLeast(1),
 			},
-			// TODO: static and dynamic memory mins and max's
+			// memory is a shortcut for memory_static_max, memory sizes are in GiB
 			"memory": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Computed:     true,
+				ExactlyOneOf: []string{"memory", "memory_static_max"},
+				ValidateFunc: validation.IntAtLeast(1),
+			},
+			// XO's vm.set can't change the static min, it comes from the template
+			"memory_static_min": {
 				Type:     schema.TypeInt,
-				Required: true,
-				ForceNew: true,
+				Computed: true,
+			},
+			// changing the static range requires the VM to be stopped
+			"memory_static_max": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Computed:     true,
+				ExactlyOneOf: []string{"memory", "memory_static_max"},
+				ValidateFunc: validation.IntAtLeast(1),
+			},
+			// the dynamic range is where ballooning keeps the memory, it's changed live
+			"memory_dynamic_min": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.IntAtLeast(1),
+			},
+			"memory_dynamic_max": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.IntAtLeast(1),
 			},
 			"installation": {
 				Type:     schema.TypeList,
 				Optional: true,
 				MaxItems: 1,
-				ForceNew: true,
 				Elem: &schema.Resource{
 					Schema: map[string]*schema.Schema{
 						"method": {
 							Type:         schema.TypeString,
 							Required:     true,
-							ForceNew:     true,
 							ValidateFunc: validation.StringInSlice([]string{"network", "cd"}, false),
 						},
 						"disk_id": {
 							Type:     schema.TypeString,
 							Optional: true,
-							ForceNew: true,
 						},
 					},
 				},
@@ -93,6 +139,11 @@ func resourceVirtualMachine() *schema.Resource {
 							Type:     schema.TypeString,
 							Required: true,
 						},
+						// the VBD of the disk, attached_disk only manages the VBDs it has the IDs of
+						"vbd_id": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
 						"device": {
 							Type:     schema.TypeString,
 							Computed: true,
@@ -114,6 +165,11 @@ func resourceVirtualMachine() *schema.Resource {
 							Type:     schema.TypeBool,
 							Computed: true,
 						},
+						// the VIF of the interface, network_interface only manages the VIFs it has the IDs of
+						"vif_id": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
 						"device": {
 							Type:     schema.TypeString,
 							Computed: true,
@@ -122,9 +178,49 @@ func resourceVirtualMachine() *schema.Resource {
 							Type:     schema.TypeString,
 							Required: true,
 						},
+						// the MAC is kept when the network of the interface changes
 						"mac_address": {
-							Type:     schema.TypeString,
-							Computed: true,
+							Type:         schema.TypeString,
+							Optional:     true,
+							Computed:     true,
+							ValidateFunc: validateMACAddress,
+							DiffSuppressFunc: func(k, old, new string, d *schema.ResourceData) bool {
+								return strings.EqualFold(old, new)
+							},
+						},
+						// the interface is recreated when the MTU changes, it defaults to the one of the network
+						"mtu": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Computed:     true,
+							ValidateFunc: validation.IntBetween(68, 65535),
+						},
+						"rate_limit_kbps": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							ValidateFunc: validation.IntAtLeast(0),
+						},
+						"locking_mode": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      "network_default",
+							ValidateFunc: validation.StringInSlice([]string{"network_default", "locked", "unlocked", "disabled"}, false),
+						},
+						"allowed_ipv4_addresses": {
+							Type:     schema.TypeSet,
+							Optional: true,
+							Elem: &schema.Schema{
+								Type:         schema.TypeString,
+								ValidateFunc: validation.IsIPv4Address,
+							},
+						},
+						"allowed_ipv6_addresses": {
+							Type:     schema.TypeSet,
+							Optional: true,
+							Elem: &schema.Schema{
+								Type:         schema.TypeString,
+								ValidateFunc: validation.IsIPv6Address,
+							},
 						},
 					},
 				},
@@ -143,6 +239,17 @@ func resourceVirtualMachine() *schema.Resource {
 			customdiff.ForceNewIfChange("boot_disk.0.size", func(ctx context.Context, old, new, meta interface{}) bool {
 				return new.(int) < old.(int)
 			}),
+			func(ctx context.Context, diff *schema.ResourceDiff, i interface{}) error {
+				cpus := diff.Get("cpus").(int)
+				maxCPUs := diff.Get("max_cpus").(int)
+
+				if maxCPUs == 0 || cpus <= maxCPUs {
+					return nil
+				}
+
+				return fmt.Errorf("cpus (%d) cannot be greater than max_cpus (%d)", cpus, maxCPUs)
+			},
+			customizeVirtualMachineMemory,
 			func(ctx context.Context, diff *schema.ResourceDiff, i interface{}) error {
 				// when creating an instance, name is not set
 				oldName, _ := diff.GetChange("name")
@@ -158,20 +265,293 @@ func resourceVirtualMachine() *schema.Resource {
 					return nil
 				}
 
+				return nil
+			},
+			func(ctx context.Context, diff *schema.ResourceDiff, i interface{}) error {
+				// template_id and installation are only used to create the VM and can't always
+				// be read back after an import, so only replace the VM when a known value changes
+				if diff.Id() == "" {
+					return nil
+				}
+
+				if oldTemplateID, _ := diff.GetChange("template_id"); oldTemplateID != "" && diff.HasChange("template_id") {
+					if err := diff.ForceNew("template_id"); err != nil {
+						return err
+					}
+				}
+
+				oldInstallation, newInstallation := diff.GetChange("installation")
+				if len(oldInstallation.([]interface{})) == 0 {
+					return nil
+				}
+
+				if len(newInstallation.([]interface{})) == 0 {
+					return diff.ForceNew("installation")
+				}
+
+				for _, key := range []string{"installation.0.method", "installation.0.disk_id"} {
+					if diff.HasChange(key) {
+						if err := diff.ForceNew(key); err != nil {
+							return err
+						}
+					}
+				}
+
 				return nil
 			},
 		),
 	}
 }
 
+// customizeVirtualMachineMemory keeps memory and memory_static_max in sync, whichever of them
+// is configured, keeps the dynamic range pinned and checks the memory ranges
+func customizeVirtualMachineMemory(ctx context.Context, diff *schema.ResourceDiff, i interface{}) error {
+	oldStaticMax, staticMax := diff.GetChange("memory_static_max")
+	oldDynamicMin, dynamicMin := diff.GetChange("memory_dynamic_min")
+	oldDynamicMax, dynamicMax := diff.GetChange("memory_dynamic_max")
+
+	// the one that isn't configured is unknown when creating the VM
+	syncMemory := false
+	if diff.HasChange("memory") && diff.NewValueKnown("memory") {
+		staticMax = diff.Get("memory")
+	} else if diff.HasChange("memory_static_max") && diff.NewValueKnown("memory_static_max") {
+		syncMemory = true
+	}
+
+	// without ballooning the dynamic range is pinned to the static max, keep it pinned when
+	// the static max changes and the range isn't configured along with it
+	if !diff.HasChange("memory_dynamic_max") && oldDynamicMax.(int) == oldStaticMax.(int) {
+		dynamicMax = staticMax
+	}
+	if !diff.HasChange("memory_dynamic_min") && oldDynamicMin.(int) == oldDynamicMax.(int) {
+		dynamicMin = dynamicMax
+	}
+
+	// unknown values are 0 until they are known
+	if dynamicMax.(int) > 0 && dynamicMin.(int) > dynamicMax.(int) {
+		return fmt.Errorf("memory_dynamic_min (%d) cannot be greater than memory_dynamic_max (%d)", dynamicMin, dynamicMax)
+	}
+
+	if staticMax.(int) > 0 && dynamicMax.(int) > staticMax.(int) {
+		return fmt.Errorf("memory_dynamic_max (%d) cannot be greater than memory_static_max (%d)", dynamicMax, staticMax)
+	}
+
+	if staticMin := diff.Get("memory_static_min").(int); staticMin > 0 && dynamicMin.(int) > 0 && dynamicMin.(int) < staticMin {
+		return fmt.Errorf("memory_dynamic_min (%d) cannot be less than memory_static_min (%d)", dynamicMin, staticMin)
+	}
+
+	// SetNew clears the diff of every attribute starting with the key it sets, so memory goes
+	// first and the memory_* attributes are set again after it
+	if syncMemory {
+		if err := diff.SetNew("memory", staticMax); err != nil {
+			return err
+		}
+		if diff.Id() == "" {
+			if err := diff.SetNewComputed("memory_static_min"); err != nil {
+				return err
+			}
+		}
+	}
+
+	changes := []struct {
+		key      string
+		old, new interface{}
+	}{
+		{"memory_static_max", oldStaticMax, staticMax},
+		{"memory_dynamic_min", oldDynamicMin, dynamicMin},
+		{"memory_dynamic_max", oldDynamicMax, dynamicMax},
+	}
+	for _, change := range changes {
+		if change.new.(int) > 0 && change.new.(int) != change.old.(int) {
+			if err := diff.SetNew(change.key, change.new); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// ownedAttachments returns the IDs of the attachments recorded in the list attribute under
+// idKey. Entries written before the IDs were recorded only have the device under deviceKey,
+// those are returned as devices.
+func ownedAttachments(d *schema.ResourceData, list, idKey, deviceKey string) (ids, devices map[string]bool) {
+	ids = map[string]bool{}
+	devices = map[string]bool{}
+	for _, item := range d.Get(list).([]interface{}) {
+		itemMap := item.(map[string]interface{})
+		if id := itemMap[idKey].(string); len(id) > 0 {
+			ids[id] = true
+		} else if device := itemMap[deviceKey].(string); len(device) > 0 {
+			devices[device] = true
+		}
+	}
+	return ids, devices
+}
+
+// managedVIFs returns the VIFs that belong to network_interface, the ones it created or
+// imported. VIFs of xenorchestra_network_interface resources on the same VM or created outside
+// of terraform are left out.
+func managedVIFs(d *schema.ResourceData, vifs []xo_client.VIF) []xo_client.VIF {
+	ids, devices := ownedAttachments(d, "network_interface", "vif_id", "device")
+
+	var managed []xo_client.VIF
+	for _, vif := range vifs {
+		if ids[vif.ID] || devices[vif.Device] {
+			managed = append(managed, vif)
+		}
+	}
+
+	return managed
+}
+
+// managedAttachedDisks returns the attached disks that belong to attached_disk, the ones it
+// attached or imported. Disks of xenorchestra_disk_attachment resources on the same VM or
+// attached outside of terraform are left out.
+func managedAttachedDisks(d *schema.ResourceData, vbds []xo_client.VBD, vdis []xo_client.VDI) ([]xo_client.VBD, []xo_client.VDI) {
+	ids, positions := ownedAttachments(d, "attached_disk", "vbd_id", "position")
+
+	var managedVBDs []xo_client.VBD
+	var managedVDIs []xo_client.VDI
+	for i, vbd := range vbds {
+		if ids[vbd.ID] || positions[vbd.Position] {
+			managedVBDs = append(managedVBDs, vbd)
+			managedVDIs = append(managedVDIs, vdis[i])
+		}
+	}
+
+	return managedVBDs, managedVDIs
+}
+
+// networkInterfaceIdentity is what identifies the VIF of a network_interface, the VIF is
+// recreated when it changes while the rest of its settings are changed with vif.set
+func networkInterfaceIdentity(vifMap map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"device":      vifMap["device"],
+		"network_id":  vifMap["network_id"],
+		"mac_address": strings.ToLower(vifMap["mac_address"].(string)),
+		"mtu":         vifMap["mtu"],
+	}
+}
+
+// networkInterfaceIdentitiesChanged reports whether any VIF has to be created or deleted to
+// go from o to n
+func networkInterfaceIdentitiesChanged(o, n []interface{}) (bool, error) {
+	if len(o) != len(n) {
+		return true, nil
+	}
+
+	for i := range o {
+		oHash, err := hashstructure.Hash(networkInterfaceIdentity(o[i].(map[string]interface{})), nil)
+		if err != nil {
+			return false, err
+		}
+
+		nHash, err := hashstructure.Hash(networkInterfaceIdentity(n[i].(map[string]interface{})), nil)
+		if err != nil {
+			return false, err
+		}
+
+		if oHash != nHash {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// networkInterfaceSettings returns the VIF settings of a network_interface
+func networkInterfaceSettings(vifMap map[string]interface{}) xo_client.VIFSettings {
+	settings := xo_client.VIFSettings{
+		LockingMode: vifMap["locking_mode"].(string),
+		RateLimit:   vifMap["rate_limit_kbps"].(int),
+	}
+
+	for _, address := range vifMap["allowed_ipv4_addresses"].(*schema.Set).List() {
+		settings.AllowedIPv4Addresses = append(settings.AllowedIPv4Addresses, address.(string))
+	}
+
+	for _, address := range vifMap["allowed_ipv6_addresses"].(*schema.Set).List() {
+		settings.AllowedIPv6Addresses = append(settings.AllowedIPv6Addresses, address.(string))
+	}
+
+	return settings
+}
+
+// setNetworkInterfaceSettings changes the settings of vif to the ones of vifMap when they
+// differ
+func setNetworkInterfaceSettings(c *xo_client.Client, ctx context.Context, vif *xo_client.VIF, vifMap map[string]interface{}) error {
+	settings := networkInterfaceSettings(vifMap)
+	if settings.Equal(vif.Settings()) {
+		return nil
+	}
+
+	return vif.Set(c, ctx, settings)
+}
+
+func resourceVirtualMachineImport(ctx context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	c := m.(*providerMeta).client
+
+	vm, err := c.GetVirtualMachineByID(ctx, d.Id())
+	if err != nil {
+		return nil, err
+	}
+
+	// XAPI records the name of the template the VM was cloned from, the template itself can
+	// be gone or renamed since then, so the template_id is left empty when it isn't found
+	if name := vm.BaseTemplateName(); len(name) > 0 {
+		template, err := c.GetTemplateByName(ctx, vm.Pool, name)
+		if err == nil {
+			d.Set("template_id", template.ID)
+		} else if errors.Is(err, xo_client.NotFoundError) || errors.Is(err, xo_client.MultipleFoundError) {
+			log.Printf("[WARN] unable to find template %s of VM %s, template_id is left empty: %v", name, vm.ID, err)
+		} else {
+			return nil, err
+		}
+	}
+
+	// an imported VM takes all the disks and VIFs it has, read fills in the rest of them
+	vbds, err := vm.GetAttachedVBDs(c, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var attachedDiskList []map[string]interface{}
+	for _, vbd := range vbds {
+		attachedDiskList = append(attachedDiskList, map[string]interface{}{
+			"disk_id": vbd.VDI,
+			"vbd_id":  vbd.ID,
+		})
+	}
+
+	d.Set("attached_disk", attachedDiskList)
+
+	vifs, err := vm.GetVIFs(c, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var networkInterfaceList []map[string]interface{}
+	for _, vif := range vifs {
+		networkInterfaceList = append(networkInterfaceList, map[string]interface{}{
+			"network_id": vif.NetworkID,
+			"vif_id":     vif.ID,
+		})
+	}
+
+	d.Set("network_interface", networkInterfaceList)
+
+	return []*schema.ResourceData{d}, nil
+}
+
 func resourceVirtualMachineCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	c := m.(*xo_client.Client)
+	c := m.(*providerMeta).client
 
 	name := d.Get("name").(string)
 	description := d.Get("description").(string)
 	templateID := d.Get("template_id").(string)
 	cpus := d.Get("cpus").(int)
-	memory := d.Get("memory").(int)
+	memory := d.Get("memory_static_max").(int)
 
 	template, err := c.GetTemplateByID(ctx, templateID)
 	if err != nil {
@@ -374,7 +754,7 @@ func resourceVirtualMachineCreate(ctx context.Context, d *schema.ResourceData, m
 		}
 	}
 
-	var networks []xo_client.Network
+	var vifs []xo_client.VirtualMachineVIF
 
 	networkInterfaceList := d.Get("network_interface").([]interface{})
 	for _, networkInterface := range networkInterfaceList {
@@ -401,7 +781,11 @@ func resourceVirtualMachineCreate(ctx context.Context, d *schema.ResourceData, m
 			}
 		}
 
-		networks = append(networks, *network)
+		vifs = append(vifs, xo_client.VirtualMachineVIF{
+			NetworkID: network.ID,
+			MAC:       networkInterfaceMap["mac_address"].(string),
+			MTU:       networkInterfaceMap["mtu"].(int),
+		})
 	}
 
 	virtualMachine, err := c.CreateVirtualMachine(
@@ -414,7 +798,7 @@ func resourceVirtualMachineCreate(ctx context.Context, d *schema.ResourceData, m
 		installation,
 		vmd,
 		existingDisk,
-		networks,
+		vifs,
 	)
 	if err != nil {
 		return diag.Diagnostics{
@@ -428,6 +812,12 @@ func resourceVirtualMachineCreate(ctx context.Context, d *schema.ResourceData, m
 
 	d.SetId(virtualMachine.ID)
 
+	locks := m.(*providerMeta).locks
+	if err := locks.Lock(ctx, vmLockKey(virtualMachine.ID)); err != nil {
+		return lockDiags(vmLockKey(virtualMachine.ID), err)
+	}
+	defer locks.Unlock(vmLockKey(virtualMachine.ID))
+
 	attachDisksList := d.Get("attached_disk").([]interface{})
 	for _, attachDisk := range attachDisksList {
 		attachDiskMap := attachDisk.(map[string]interface{})
@@ -444,7 +834,11 @@ func resourceVirtualMachineCreate(ctx context.Context, d *schema.ResourceData, m
 			}
 		}
 
-		err = virtualMachine.AttachDisk(c, ctx, vdi)
+		if err := locks.Lock(ctx, vdiLockKey(vdi.ID)); err != nil {
+			return lockDiags(vdiLockKey(vdi.ID), err)
+		}
+		vbd, err := virtualMachine.AttachDisk(c, ctx, vdi, "", false, "")
+		locks.Unlock(vdiLockKey(vdi.ID))
 		if err != nil {
 			return diag.Diagnostics{
 				{
@@ -454,6 +848,79 @@ func resourceVirtualMachineCreate(ctx context.Context, d *schema.ResourceData, m
 				},
 			}
 		}
+
+		// recorded as they are attached, the disks attached so far are kept when a later one fails
+		attachDiskMap["vbd_id"] = vbd.ID
+		d.Set("attached_disk", attachDisksList)
+	}
+
+	// vm.create can't set the rest of the VIF settings, the VIFs are created in order so the
+	// device of each is its index
+	createdVIFs, err := virtualMachine.GetVIFs(c, ctx)
+	if err != nil {
+		return diag.Diagnostics{
+			{
+				Severity: diag.Error,
+				Summary:  "Error getting vifs of virtual machine",
+				Detail:   err.Error(),
+			},
+		}
+	}
+
+	for _, vif := range createdVIFs {
+		i, err := strconv.Atoi(vif.Device)
+		if err != nil || i >= len(networkInterfaceList) {
+			continue
+		}
+		networkInterfaceList[i].(map[string]interface{})["vif_id"] = vif.ID
+	}
+	d.Set("network_interface", networkInterfaceList)
+
+	for _, vif := range createdVIFs {
+		i, err := strconv.Atoi(vif.Device)
+		if err != nil || i >= len(networkInterfaceList) {
+			continue
+		}
+
+		err = setNetworkInterfaceSettings(c, ctx, &vif, networkInterfaceList[i].(map[string]interface{}))
+		if err != nil {
+			return diag.Diagnostics{
+				{
+					Severity: diag.Error,
+					Summary:  fmt.Sprintf("Error changing settings of vif %s", vif.ID),
+					Detail:   err.Error(),
+				},
+			}
+		}
+	}
+
+	// vm.create pins the dynamic range to the memory, set it when ballooning is configured
+	dynamicMin := d.Get("memory_dynamic_min").(int)
+	dynamicMax := d.Get("memory_dynamic_max").(int)
+	if dynamicMin != memory || dynamicMax != memory {
+		err = virtualMachine.SetMemory(c, ctx, memory*1024*1024*1024, dynamicMin*1024*1024*1024, dynamicMax*1024*1024*1024)
+		if err != nil {
+			return diag.Diagnostics{
+				{
+					Severity: diag.Error,
+					Summary:  "Error setting dynamic memory of virtual machine",
+					Detail:   err.Error(),
+				},
+			}
+		}
+	}
+
+	if maxCPUs, ok := d.GetOk("max_cpus"); ok && maxCPUs.(int) != virtualMachine.CPU.Max {
+		err = virtualMachine.SetCPUs(c, ctx, cpus, maxCPUs.(int))
+		if err != nil {
+			return diag.Diagnostics{
+				{
+					Severity: diag.Error,
+					Summary:  "Error setting max_cpus of virtual machine",
+					Detail:   err.Error(),
+				},
+			}
+		}
 	}
 
 	err = virtualMachine.Start(c, ctx)
@@ -467,15 +934,26 @@ func resourceVirtualMachineCreate(ctx context.Context, d *schema.ResourceData, m
 		}
 	}
 
+	err = virtualMachine.WaitForPowerState(c, ctx, "Running")
+	if err != nil {
+		return diag.Diagnostics{
+			{
+				Severity: diag.Error,
+				Summary:  "Error waiting for virtual machine to start",
+				Detail:   err.Error(),
+			},
+		}
+	}
+
 	return resourceVirtualMachineRead(ctx, d, m)
 }
 
 func resourceVirtualMachineRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	c := m.(*xo_client.Client)
+	c := m.(*providerMeta).client
 
 	vm, err := c.GetVirtualMachineByID(ctx, d.Id())
 	if err != nil {
-		if err == xo_client.NotFoundError {
+		if errors.Is(err, xo_client.NotFoundError) {
 			d.SetId("")
 			return nil
 		}
@@ -490,14 +968,21 @@ func resourceVirtualMachineRead(ctx context.Context, d *schema.ResourceData, m i
 
 	d.Set("name", vm.Name)
 	d.Set("description", vm.Description)
-	d.Set("cpus", vm.CPU.Max)
+	d.Set("cpus", vm.CPU.Number)
+	if _, ok := d.GetOk("max_cpus"); ok {
+		d.Set("max_cpus", vm.CPU.Max)
+	}
 	d.Set("memory", vm.Memory.Static[1]/1024/1024/1024)
+	d.Set("memory_static_min", vm.Memory.Static[0]/1024/1024/1024)
+	d.Set("memory_static_max", vm.Memory.Static[1]/1024/1024/1024)
+	d.Set("memory_dynamic_min", vm.Memory.Dynamic[0]/1024/1024/1024)
+	d.Set("memory_dynamic_max", vm.Memory.Dynamic[1]/1024/1024/1024)
 
 	var bootDiskList []map[string]interface{}
 
 	bootVDI, err := vm.GetBootDisk(c, ctx)
 	if err != nil {
-		if err != xo_client.NotFoundError {
+		if !errors.Is(err, xo_client.NotFoundError) {
 			return diag.Diagnostics{
 				{
 					Severity: diag.Error,
@@ -521,7 +1006,7 @@ func resourceVirtualMachineRead(ctx context.Context, d *schema.ResourceData, m i
 
 	attachedVBDs, attchedVDIs, err := vm.GetAttachedDisks(c, ctx)
 	if err != nil {
-		if err != xo_client.NotFoundError {
+		if !errors.Is(err, xo_client.NotFoundError) {
 			return diag.Diagnostics{
 				{
 					Severity: diag.Error,
@@ -532,12 +1017,14 @@ func resourceVirtualMachineRead(ctx context.Context, d *schema.ResourceData, m i
 		}
 	}
 
+	attachedVBDs, attchedVDIs = managedAttachedDisks(d, attachedVBDs, attchedVDIs)
 	for i, attachedDisk := range attchedVDIs {
 		vbd := attachedVBDs[i]
 		// disks are listed backwards so reverse the append
 		attachedDiskList = append([]map[string]interface{}{
 			{
 				"disk_id":  attachedDisk.ID,
+				"vbd_id":   vbd.ID,
 				"device":   vbd.Device,
 				"position": vbd.Position,
 			},
@@ -550,7 +1037,7 @@ func resourceVirtualMachineRead(ctx context.Context, d *schema.ResourceData, m i
 
 	vifs, err := vm.GetVIFs(c, ctx)
 	if err != nil {
-		if err != xo_client.NotFoundError {
+		if !errors.Is(err, xo_client.NotFoundError) {
 			return diag.Diagnostics{
 				{
 					Severity: diag.Error,
@@ -561,14 +1048,20 @@ func resourceVirtualMachineRead(ctx context.Context, d *schema.ResourceData, m i
 		}
 	}
 
-	for _, vif := range vifs {
+	for _, vif := range managedVIFs(d, vifs) {
 		// vifs are listed backwards so reverse the append
 		networkInterfaceList = append([]map[string]interface{}{
 			{
-				"attached":    vif.Attached,
-				"device":      vif.Device,
-				"network_id":  vif.NetworkID,
-				"mac_address": vif.MAC,
+				"attached":               vif.Attached,
+				"vif_id":                 vif.ID,
+				"device":                 vif.Device,
+				"network_id":             vif.NetworkID,
+				"mac_address":            vif.MAC,
+				"mtu":                    vif.MTU,
+				"rate_limit_kbps":        vif.RateLimit,
+				"locking_mode":           vif.LockingMode,
+				"allowed_ipv4_addresses": vif.AllowedIPv4Addresses,
+				"allowed_ipv6_addresses": vif.AllowedIPv6Addresses,
 			},
 		}, networkInterfaceList...)
 	}
@@ -583,7 +1076,7 @@ func resourceVirtualMachineRead(ctx context.Context, d *schema.ResourceData, m i
 }
 
 func resourceVirtualMachineUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	c := m.(*xo_client.Client)
+	c := m.(*providerMeta).client
 
 	var name *string
 	var description *string
@@ -591,9 +1084,15 @@ func resourceVirtualMachineUpdate(ctx context.Context, d *schema.ResourceData, m
 	allowStoppingForUpdate := d.Get("allow_stopping_for_update").(bool)
 	stoppedForUpdate := false
 
+	locks := m.(*providerMeta).locks
+	if err := locks.Lock(ctx, vmLockKey(d.Id())); err != nil {
+		return lockDiags(vmLockKey(d.Id()), err)
+	}
+	defer locks.Unlock(vmLockKey(d.Id()))
+
 	vm, err := c.GetVirtualMachineByID(ctx, d.Id())
 	if err != nil {
-		if err == xo_client.NotFoundError {
+		if errors.Is(err, xo_client.NotFoundError) {
 			d.SetId("")
 			return nil
 		}
@@ -614,7 +1113,7 @@ func resourceVirtualMachineUpdate(ctx context.Context, d *schema.ResourceData, m
 	}
 
 	if d.HasChange("description") {
-		name = func(i string) *string { return &i }(d.Get("description").(string))
+		description = func(i string) *string { return &i }(d.Get("description").(string))
 	}
 
 	err = vm.Update(c, ctx, name, description)
@@ -631,17 +1130,60 @@ func resourceVirtualMachineUpdate(ctx context.Context, d *schema.ResourceData, m
 	bootDiskChanged := d.HasChange("boot_disk.0.size")
 	attachDiskChanged := d.HasChange("attached_disk")
 	networkChanged := d.HasChange("network_interface")
+	cpusChanged := d.HasChange("cpus") || d.HasChange("max_cpus")
+	memoryChanged := d.HasChanges("memory_static_max", "memory_dynamic_min", "memory_dynamic_max")
+
+	cpus := d.Get("cpus").(int)
+	maxCPUs := d.Get("max_cpus").(int)
+	if maxCPUs == 0 {
+		maxCPUs = vm.CPU.Max
+		if cpus > maxCPUs {
+			maxCPUs = cpus
+		}
+	}
+	staticMax := d.Get("memory_static_max").(int) * 1024 * 1024 * 1024
 
 	// trying to change disks without pv drivers while running
 	// this requires a power off
-	if vm.PVDriversDetected == false && currentStatus == "Running" && (bootDiskChanged || attachDiskChanged || networkChanged) {
+	// VIF settings are changed live, only recreating VIFs counts
+	vifsRecreated := false
+	if networkChanged {
+		o, n := d.GetChange("network_interface")
+		vifsRecreated, err = networkInterfaceIdentitiesChanged(o.([]interface{}), n.([]interface{}))
+		if err != nil {
+			return diag.Diagnostics{
+				{
+					Severity: diag.Error,
+					Summary:  "Error hashing vif",
+					Detail:   err.Error(),
+				},
+			}
+		}
+	}
+
+	disksNeedStop := vm.PVDriversDetected == false && currentStatus == "Running" && (bootDiskChanged || attachDiskChanged || vifsRecreated)
+
+	// vCPUs can be hot added up to the current max, changing the max requires a power off
+	cpusNeedStop := cpusChanged && currentStatus == "Running" && maxCPUs != vm.CPU.Max
 
-		// can't change attached disks when running
+	// the dynamic memory range is changed live, the static range requires a power off
+	memoryNeedsStop := memoryChanged && currentStatus == "Running" && staticMax != vm.Memory.Static[1]
+
+	if disksNeedStop || cpusNeedStop || memoryNeedsStop {
+
+		// can't change attached disks or the max vCPUs when running
 		if allowStoppingForUpdate == false {
+			summary := "Cannot change attached disks when VM is running without PV drivers unless allow_stopping_for_update is set to true"
+			if cpusNeedStop {
+				summary = fmt.Sprintf("Cannot change max_cpus from %d to %d when VM is running unless allow_stopping_for_update is set to true", vm.CPU.Max, maxCPUs)
+			} else if memoryNeedsStop {
+				summary = "Cannot change memory_static_max when VM is running unless allow_stopping_for_update is set to true"
+			}
+
 			return diag.Diagnostics{
 				{
 					Severity: diag.Error,
-					Summary:  "Cannot change attached disks when VM is running without PV drivers unless allow_stopping_for_update is set to true",
+					Summary:  summary,
 				},
 			}
 		}
@@ -651,7 +1193,18 @@ func resourceVirtualMachineUpdate(ctx context.Context, d *schema.ResourceData, m
 			return diag.Diagnostics{
 				{
 					Severity: diag.Error,
-					Summary:  "Error stopping VM for boot disk resize",
+					Summary:  "Error stopping VM for update",
+					Detail:   err.Error(),
+				},
+			}
+		}
+
+		err = vm.WaitForPowerState(c, ctx, "Halted")
+		if err != nil {
+			return diag.Diagnostics{
+				{
+					Severity: diag.Error,
+					Summary:  "Error waiting for virtual machine to stop for update",
 					Detail:   err.Error(),
 				},
 			}
@@ -659,6 +1212,35 @@ func resourceVirtualMachineUpdate(ctx context.Context, d *schema.ResourceData, m
 		stoppedForUpdate = true
 	}
 
+	if cpusChanged {
+		err := vm.SetCPUs(c, ctx, cpus, maxCPUs)
+		if err != nil {
+			return diag.Diagnostics{
+				{
+					Severity: diag.Error,
+					Summary:  "Error changing cpus of virtual machine",
+					Detail:   err.Error(),
+				},
+			}
+		}
+	}
+
+	if memoryChanged {
+		dynamicMin := d.Get("memory_dynamic_min").(int) * 1024 * 1024 * 1024
+		dynamicMax := d.Get("memory_dynamic_max").(int) * 1024 * 1024 * 1024
+
+		err := vm.SetMemory(c, ctx, staticMax, dynamicMin, dynamicMax)
+		if err != nil {
+			return diag.Diagnostics{
+				{
+					Severity: diag.Error,
+					Summary:  "Error changing memory of virtual machine",
+					Detail:   err.Error(),
+				},
+			}
+		}
+	}
+
 	if bootDiskChanged {
 		size := d.Get("boot_disk.0.size").(int) * 1024 * 1024 * 1024
 		bootVDI, err := vm.GetBootDisk(c, ctx)
@@ -672,7 +1254,11 @@ func resourceVirtualMachineUpdate(ctx context.Context, d *schema.ResourceData, m
 			}
 		}
 
+		if err := locks.Lock(ctx, vdiLockKey(bootVDI.ID)); err != nil {
+			return lockDiags(vdiLockKey(bootVDI.ID), err)
+		}
 		err = bootVDI.Update(c, ctx, nil, nil, &size)
+		locks.Unlock(vdiLockKey(bootVDI.ID))
 		if err != nil {
 			return diag.Diagnostics{
 				{
@@ -701,7 +1287,7 @@ func resourceVirtualMachineUpdate(ctx context.Context, d *schema.ResourceData, m
 		// disks currently attached than there were at the time we ran terraform plan.
 		currDisks := map[string]xo_client.VBD{}
 		for _, disk := range vbds {
-			currDisks[disk.Position] = disk
+			currDisks[disk.ID] = disk
 		}
 
 		// Keep track of disks currently in state.
@@ -710,7 +1296,7 @@ func resourceVirtualMachineUpdate(ctx context.Context, d *schema.ResourceData, m
 		oDisks := map[uint64]xo_client.VBD{}
 		for _, disk := range o.([]interface{}) {
 			diskMap := disk.(map[string]interface{})
-			position := diskMap["position"].(string)
+			vbdID := diskMap["vbd_id"].(string)
 			hash, err := hashstructure.Hash(diskMap, nil)
 			if err != nil {
 				return diag.Diagnostics{
@@ -722,7 +1308,7 @@ func resourceVirtualMachineUpdate(ctx context.Context, d *schema.ResourceData, m
 				}
 			}
 
-			if vbd, ok := currDisks[position]; ok {
+			if vbd, ok := currDisks[vbdID]; ok {
 				oDisks[hash] = vbd
 			}
 		}
@@ -756,10 +1342,15 @@ func resourceVirtualMachineUpdate(ctx context.Context, d *schema.ResourceData, m
 		// Detach the old disks.
 		for hash, vbd := range oDisks {
 			if _, ok := nDisks[hash]; !ok {
+				if err := locks.Lock(ctx, vdiLockKey(vbd.VDI)); err != nil {
+					return lockDiags(vdiLockKey(vbd.VDI), err)
+				}
+
 				// if running we need to detach first
 				if stoppedForUpdate == false && currentStatus == "Running" {
 					err := vbd.Disconnect(c, ctx)
 					if err != nil {
+						locks.Unlock(vdiLockKey(vbd.VDI))
 						return diag.Diagnostics{
 							{
 								Severity: diag.Error,
@@ -771,6 +1362,7 @@ func resourceVirtualMachineUpdate(ctx context.Context, d *schema.ResourceData, m
 				}
 
 				err := vbd.Delete(c, ctx)
+				locks.Unlock(vdiLockKey(vbd.VDI))
 				if err != nil {
 					return diag.Diagnostics{
 						{
@@ -798,7 +1390,11 @@ func resourceVirtualMachineUpdate(ctx context.Context, d *schema.ResourceData, m
 				}
 			}
 
-			err = vm.AttachDisk(c, ctx, vdi)
+			if err := locks.Lock(ctx, vdiLockKey(vdi.ID)); err != nil {
+				return lockDiags(vdiLockKey(vdi.ID), err)
+			}
+			vbd, err := vm.AttachDisk(c, ctx, vdi, "", false, "")
+			locks.Unlock(vdiLockKey(vdi.ID))
 			if err != nil {
 				return diag.Diagnostics{
 					{
@@ -808,6 +1404,13 @@ func resourceVirtualMachineUpdate(ctx context.Context, d *schema.ResourceData, m
 					},
 				}
 			}
+
+			// attach holds the maps of n, so this records the VBD the disk got, read only
+			// picks up the VBDs attached_disk has the IDs of
+			diskMap["vbd_id"] = vbd.ID
+			diskMap["device"] = vbd.Device
+			diskMap["position"] = vbd.Position
+			d.Set("attached_disk", n)
 		}
 	}
 
@@ -826,14 +1429,14 @@ func resourceVirtualMachineUpdate(ctx context.Context, d *schema.ResourceData, m
 
 		currVIFs := map[string]xo_client.VIF{}
 		for _, vif := range vifs {
-			currVIFs[vif.Device] = vif
+			currVIFs[vif.ID] = vif
 		}
 
 		oVIFs := map[uint64]xo_client.VIF{}
 		for _, vif := range o.([]interface{}) {
 			vifMap := vif.(map[string]interface{})
-			position := vifMap["device"].(string)
-			hash, err := hashstructure.Hash(vifMap, nil)
+			vifID := vifMap["vif_id"].(string)
+			hash, err := hashstructure.Hash(networkInterfaceIdentity(vifMap), nil)
 			if err != nil {
 				return diag.Diagnostics{
 					{
@@ -844,7 +1447,7 @@ func resourceVirtualMachineUpdate(ctx context.Context, d *schema.ResourceData, m
 				}
 			}
 
-			if vif, ok := currVIFs[position]; ok {
+			if vif, ok := currVIFs[vifID]; ok {
 				oVIFs[hash] = vif
 			}
 		}
@@ -853,20 +1456,34 @@ func resourceVirtualMachineUpdate(ctx context.Context, d *schema.ResourceData, m
 		var attach []map[string]interface{}
 		for _, vif := range n.([]interface{}) {
 			vifMap := vif.(map[string]interface{})
-			hash, err := hashstructure.Hash(vifMap, nil)
+			hash, err := hashstructure.Hash(networkInterfaceIdentity(vifMap), nil)
 			if err != nil {
 				return diag.Diagnostics{
 					{
 						Severity: diag.Error,
-						Summary:  "Error hashing disk",
+						Summary:  "Error hashing vif",
 						Detail:   err.Error(),
 					},
 				}
 			}
 			nVIFs[hash] = struct{}{}
 
-			if _, ok := oVIFs[hash]; !ok {
+			existing, ok := oVIFs[hash]
+			if !ok {
 				attach = append(attach, vifMap)
+				continue
+			}
+
+			// the VIF stays, only its settings can have changed
+			err = setNetworkInterfaceSettings(c, ctx, &existing, vifMap)
+			if err != nil {
+				return diag.Diagnostics{
+					{
+						Severity: diag.Error,
+						Summary:  fmt.Sprintf("Error changing settings of vif %s", existing.ID),
+						Detail:   err.Error(),
+					},
+				}
 			}
 		}
 
@@ -913,7 +1530,8 @@ func resourceVirtualMachineUpdate(ctx context.Context, d *schema.ResourceData, m
 				}
 			}
 
-			err = vm.AttachNetwork(c, ctx, network)
+			// a VIF whose network changed is recreated with the MAC it had
+			vif, err := vm.AttachNetwork(c, ctx, network, "", vifMap["mac_address"].(string), vifMap["mtu"].(int))
 			if err != nil {
 				return diag.Diagnostics{
 					{
@@ -923,8 +1541,23 @@ func resourceVirtualMachineUpdate(ctx context.Context, d *schema.ResourceData, m
 					},
 				}
 			}
-		}
 
+			// attach holds the maps of n, so this records the VIF the interface got
+			vifMap["vif_id"] = vif.ID
+			vifMap["device"] = vif.Device
+			d.Set("network_interface", n)
+
+			err = setNetworkInterfaceSettings(c, ctx, vif, vifMap)
+			if err != nil {
+				return diag.Diagnostics{
+					{
+						Severity: diag.Error,
+						Summary:  fmt.Sprintf("Error changing settings of vif %s", vif.ID),
+						Detail:   err.Error(),
+					},
+				}
+			}
+		}
 	}
 
 	desiredStatus := d.Get("desired_status")
@@ -939,6 +1572,17 @@ func resourceVirtualMachineUpdate(ctx context.Context, d *schema.ResourceData, m
 				},
 			}
 		}
+
+		err = vm.WaitForPowerState(c, ctx, "Running")
+		if err != nil {
+			return diag.Diagnostics{
+				{
+					Severity: diag.Error,
+					Summary:  "Error waiting for virtual machine to start after update",
+					Detail:   err.Error(),
+				},
+			}
+		}
 	}
 
 	if desiredStatus != "" && (stoppedForUpdate == true || vm.PowerState != desiredStatus) {
@@ -953,6 +1597,17 @@ func resourceVirtualMachineUpdate(ctx context.Context, d *schema.ResourceData, m
 					},
 				}
 			}
+
+			err = vm.WaitForPowerState(c, ctx, "Halted")
+			if err != nil {
+				return diag.Diagnostics{
+					{
+						Severity: diag.Error,
+						Summary:  "Error waiting for virtual machine to stop",
+						Detail:   err.Error(),
+					},
+				}
+			}
 		} else if desiredStatus == "Running" {
 			err := vm.Start(c, ctx)
 			if err != nil {
@@ -964,6 +1619,17 @@ func resourceVirtualMachineUpdate(ctx context.Context, d *schema.ResourceData, m
 					},
 				}
 			}
+
+			err = vm.WaitForPowerState(c, ctx, "Running")
+			if err != nil {
+				return diag.Diagnostics{
+					{
+						Severity: diag.Error,
+						Summary:  "Error waiting for virtual machine to start",
+						Detail:   err.Error(),
+					},
+				}
+			}
 		}
 	}
 
@@ -971,11 +1637,17 @@ func resourceVirtualMachineUpdate(ctx context.Context, d *schema.ResourceData, m
 }
 
 func resourceVirtualMachineDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	c := m.(*xo_client.Client)
+	c := m.(*providerMeta).client
+
+	locks := m.(*providerMeta).locks
+	if err := locks.Lock(ctx, vmLockKey(d.Id())); err != nil {
+		return lockDiags(vmLockKey(d.Id()), err)
+	}
+	defer locks.Unlock(vmLockKey(d.Id()))
 
 	vm, err := c.GetVirtualMachineByID(ctx, d.Id())
 	if err != nil {
-		if err == xo_client.NotFoundError {
+		if errors.Is(err, xo_client.NotFoundError) {
 			d.SetId("")
 			return nil
 		}
@@ -1000,6 +1672,17 @@ func resourceVirtualMachineDelete(ctx context.Context, d *schema.ResourceData, m
 				},
 			}
 		}
+
+		err = vm.WaitForPowerState(c, ctx, "Halted")
+		if err != nil {
+			return diag.Diagnostics{
+				{
+					Severity: diag.Error,
+					Summary:  "Error waiting for virtual machine to stop",
+					Detail:   err.Error(),
+				},
+			}
+		}
 	}
 
 	vbds, err := vm.GetAttachedVBDs(c, ctx)
@@ -1014,7 +1697,11 @@ func resourceVirtualMachineDelete(ctx context.Context, d *schema.ResourceData, m
 	}
 
 	for _, vbd := range vbds {
+		if err := locks.Lock(ctx, vdiLockKey(vbd.VDI)); err != nil {
+			return lockDiags(vdiLockKey(vbd.VDI), err)
+		}
 		err := vbd.Delete(c, ctx)
+		locks.Unlock(vdiLockKey(vbd.VDI))
 		if err != nil {
 			return diag.Diagnostics{
 				{
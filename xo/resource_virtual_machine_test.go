@@ -0,0 +1,1189 @@
+package xo
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+
+	"github.com/rmb938/terraform-provider-xenorchestra/xo_client"
+	"github.com/rmb938/terraform-provider-xenorchestra/xo_client/xotest"
+)
+
+func (f *testAccFixtures) virtualMachineConfig(name string, diskIDs []string, networkIDs ...string) map[string]interface{} {
+	var attachedDisks []interface{}
+	for _, diskID := range diskIDs {
+		attachedDisks = append(attachedDisks, map[string]interface{}{
+			"disk_id": diskID,
+		})
+	}
+
+	var networkInterfaces []interface{}
+	for _, networkID := range networkIDs {
+		networkInterfaces = append(networkInterfaces, map[string]interface{}{
+			"network_id": networkID,
+		})
+	}
+
+	config := map[string]interface{}{
+		"name":        name,
+		"template_id": f.templateID,
+		"cpus":        2,
+		"memory":      4,
+		"boot_disk": []interface{}{
+			map[string]interface{}{
+				"storage_repository_id": f.storageRepositoryID,
+				"size":                  10,
+			},
+		},
+		"network_interface": networkInterfaces,
+	}
+
+	if len(attachedDisks) > 0 {
+		config["attached_disk"] = attachedDisks
+	}
+
+	return config
+}
+
+const testAccVirtualMachine = "xenorchestra_virtual_machine.test"
+
+// checkVirtualMachineObject checks the VM vmID as the fake server has it
+func (f *testAccFixtures) checkVirtualMachineObject(vmID string, check func(vm map[string]interface{}) error) error {
+	vm := f.server.Object(vmID)
+	if vm == nil {
+		return fmt.Errorf("VM %s does not exist", vmID)
+	}
+	return check(vm)
+}
+
+// checkVirtualMachine checks the VM of the resource name as the fake server has it
+func (f *testAccFixtures) checkVirtualMachine(name string, check func(vm map[string]interface{}) error) resource.TestCheckFunc {
+	return testAccCheckInstance(name, func(is *terraform.InstanceState) error {
+		return f.checkVirtualMachineObject(is.ID, check)
+	})
+}
+
+func (f *testAccFixtures) checkPowerState(name, powerState string) resource.TestCheckFunc {
+	return f.checkVirtualMachine(name, func(vm map[string]interface{}) error {
+		if vm["power_state"] != powerState {
+			return fmt.Errorf("expected power state %s, got %v", powerState, vm["power_state"])
+		}
+		return nil
+	})
+}
+
+// checkAttachedDisksOf checks the VDIs attached to the VM vmID besides the boot disk
+func (f *testAccFixtures) checkAttachedDisksOf(vmID string, diskIDs ...string) error {
+	return f.checkVirtualMachineObject(vmID, func(vm map[string]interface{}) error {
+		var attached []string
+		for _, vbdID := range vm["$VBDs"].([]interface{}) {
+			vbd := f.server.Object(vbdID.(string))
+			if vbd == nil {
+				return fmt.Errorf("VBD %s does not exist", vbdID)
+			}
+
+			if vbd["position"] != "0" {
+				attached = append(attached, vbd["VDI"].(string))
+			}
+		}
+
+		expected := append([]string{}, diskIDs...)
+		sort.Strings(attached)
+		sort.Strings(expected)
+		if fmt.Sprint(attached) != fmt.Sprint(expected) {
+			return fmt.Errorf("expected disks %v attached, got %v", expected, attached)
+		}
+		return nil
+	})
+}
+
+// checkAttachedDisks checks the VDIs attached to the VM of the resource name besides the boot disk
+func (f *testAccFixtures) checkAttachedDisks(name string, diskIDs ...string) resource.TestCheckFunc {
+	return testAccCheckInstance(name, func(is *terraform.InstanceState) error {
+		return f.checkAttachedDisksOf(is.ID, diskIDs...)
+	})
+}
+
+// checkNetworksOf checks the networks of the VIFs on the VM vmID
+func (f *testAccFixtures) checkNetworksOf(vmID string, networkIDs ...string) error {
+	return f.checkVirtualMachineObject(vmID, func(vm map[string]interface{}) error {
+		var networks []string
+		for _, vifID := range vm["VIFs"].([]interface{}) {
+			vif := f.server.Object(vifID.(string))
+			if vif == nil {
+				return fmt.Errorf("VIF %s does not exist", vifID)
+			}
+			networks = append(networks, vif["$network"].(string))
+		}
+
+		expected := append([]string{}, networkIDs...)
+		sort.Strings(networks)
+		sort.Strings(expected)
+		if fmt.Sprint(networks) != fmt.Sprint(expected) {
+			return fmt.Errorf("expected networks %v, got %v", expected, networks)
+		}
+		return nil
+	})
+}
+
+// checkNetworks checks the networks of the VIFs on the VM of the resource name
+func (f *testAccFixtures) checkNetworks(name string, networkIDs ...string) resource.TestCheckFunc {
+	return testAccCheckInstance(name, func(is *terraform.InstanceState) error {
+		return f.checkNetworksOf(is.ID, networkIDs...)
+	})
+}
+
+func TestAccVirtualMachine_basic(t *testing.T) {
+	f := testAccSetup(t)
+
+	config := f.virtualMachineConfig("test-vm", nil, f.networkID)
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy:      f.checkDestroyed("xenorchestra_virtual_machine"),
+		Steps: []resource.TestStep{
+			{
+				Config: f.resourceConfig("xenorchestra_virtual_machine", config),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "name", "test-vm"),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "cpus", "2"),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "memory", "4"),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "boot_disk.0.size", "10"),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "boot_disk.0.storage_repository_id", f.storageRepositoryID),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "network_interface.#", "1"),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "network_interface.0.network_id", f.networkID),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "network_interface.0.device", "0"),
+					resource.TestCheckResourceAttrSet(testAccVirtualMachine, "network_interface.0.mac_address"),
+					f.checkPowerState(testAccVirtualMachine, "Running"),
+				),
+			},
+			{
+				Config: f.resourceConfig("xenorchestra_virtual_machine", withConfig(withConfig(config, "name", "renamed-vm"), "description", "described")),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "name", "renamed-vm"),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "description", "described"),
+					f.checkVirtualMachine(testAccVirtualMachine, func(vm map[string]interface{}) error {
+						if vm["name_label"] != "renamed-vm" || vm["name_description"] != "described" {
+							return fmt.Errorf("VM not updated: %v %v", vm["name_label"], vm["name_description"])
+						}
+						return nil
+					}),
+				),
+			},
+		},
+	})
+}
+
+func TestAccVirtualMachine_installation(t *testing.T) {
+	f := testAccSetup(t)
+
+	config := f.virtualMachineConfig("test-vm", nil, f.networkID)
+	config["template_id"] = f.emptyTemplateID
+	config["installation"] = []interface{}{
+		map[string]interface{}{
+			"method":  "cd",
+			"disk_id": f.isoID,
+		},
+	}
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy:      f.checkDestroyed("xenorchestra_virtual_machine"),
+		Steps: []resource.TestStep{
+			{
+				Config: f.resourceConfig("xenorchestra_virtual_machine", withConfig(config, "installation", []interface{}{
+					map[string]interface{}{
+						"method": "cd",
+					},
+				})),
+				ExpectError: regexp.MustCompile("disk_id must be set when method is cd"),
+			},
+			{
+				Config: f.resourceConfig("xenorchestra_virtual_machine", config),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "boot_disk.0.size", "10"),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "attached_disk.#", "0"),
+					f.checkPowerState(testAccVirtualMachine, "Running"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccVirtualMachine_attachedDisks(t *testing.T) {
+	f := testAccSetup(t)
+	f.server.SetPVDriversDetected(true)
+
+	diskA := f.server.AddVDI(f.storageRepositoryID, "disk-a", 1*testAccGiB)
+	diskB := f.server.AddVDI(f.storageRepositoryID, "disk-b", 1*testAccGiB)
+	diskC := f.server.AddVDI(f.storageRepositoryID, "disk-c", 1*testAccGiB)
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy: resource.ComposeTestCheckFunc(
+			f.checkDestroyed("xenorchestra_virtual_machine"),
+			// detached disks are left alone
+			func(s *terraform.State) error {
+				for _, diskID := range []string{diskA, diskB, diskC} {
+					if f.server.Object(diskID) == nil {
+						return fmt.Errorf("disk %s was deleted", diskID)
+					}
+				}
+				return nil
+			},
+		),
+		Steps: []resource.TestStep{
+			{
+				Config: f.resourceConfig("xenorchestra_virtual_machine", f.virtualMachineConfig("test-vm", []string{diskA}, f.networkID)),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "attached_disk.#", "1"),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "attached_disk.0.disk_id", diskA),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "attached_disk.0.position", "1"),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "attached_disk.0.device", "xvdb"),
+					f.checkAttachedDisks(testAccVirtualMachine, diskA),
+				),
+			},
+			{
+				// appending keeps the existing disk attached
+				Config: f.resourceConfig("xenorchestra_virtual_machine", f.virtualMachineConfig("test-vm", []string{diskA, diskB}, f.networkID)),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "attached_disk.#", "2"),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "attached_disk.0.disk_id", diskA),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "attached_disk.1.disk_id", diskB),
+					f.checkAttachedDisks(testAccVirtualMachine, diskA, diskB),
+				),
+			},
+			{
+				// removing the first disk shifts the list
+				Config: f.resourceConfig("xenorchestra_virtual_machine", f.virtualMachineConfig("test-vm", []string{diskB, diskC}, f.networkID)),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "attached_disk.#", "2"),
+					f.checkAttachedDisks(testAccVirtualMachine, diskB, diskC),
+					f.checkPowerState(testAccVirtualMachine, "Running"),
+				),
+			},
+			{
+				Config: f.resourceConfig("xenorchestra_virtual_machine", f.virtualMachineConfig("test-vm", nil, f.networkID)),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "attached_disk.#", "0"),
+					f.checkAttachedDisks(testAccVirtualMachine),
+				),
+			},
+		},
+	})
+}
+
+func TestAccVirtualMachine_networkInterfaces(t *testing.T) {
+	f := testAccSetup(t)
+	f.server.SetPVDriversDetected(true)
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy:      f.checkDestroyed("xenorchestra_virtual_machine"),
+		Steps: []resource.TestStep{
+			{
+				Config: f.resourceConfig("xenorchestra_virtual_machine", f.virtualMachineConfig("test-vm", nil, f.networkID)),
+				Check:  f.checkNetworks(testAccVirtualMachine, f.networkID),
+			},
+			{
+				Config: f.resourceConfig("xenorchestra_virtual_machine", f.virtualMachineConfig("test-vm", nil, f.networkID, f.otherNetworkID)),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "network_interface.#", "2"),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "network_interface.0.network_id", f.networkID),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "network_interface.1.network_id", f.otherNetworkID),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "network_interface.1.device", "1"),
+					f.checkNetworks(testAccVirtualMachine, f.networkID, f.otherNetworkID),
+				),
+			},
+			{
+				Config: f.resourceConfig("xenorchestra_virtual_machine", f.virtualMachineConfig("test-vm", nil, f.otherNetworkID)),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "network_interface.#", "1"),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "network_interface.0.network_id", f.otherNetworkID),
+					f.checkNetworks(testAccVirtualMachine, f.otherNetworkID),
+					f.checkPowerState(testAccVirtualMachine, "Running"),
+				),
+			},
+		},
+	})
+}
+
+// checkMACs checks the MAC of the VIF on each network of the VM of the resource name
+func (f *testAccFixtures) checkMACs(name string, macs func() map[string]string) resource.TestCheckFunc {
+	return f.checkVirtualMachine(name, func(vm map[string]interface{}) error {
+		got := map[string]string{}
+		for _, vifID := range vm["VIFs"].([]interface{}) {
+			vif := f.server.Object(vifID.(string))
+			if vif == nil {
+				return fmt.Errorf("VIF %s does not exist", vifID)
+			}
+			got[vif["$network"].(string)] = vif["MAC"].(string)
+		}
+
+		if expected := macs(); fmt.Sprint(got) != fmt.Sprint(expected) {
+			return fmt.Errorf("expected MACs %v, got %v", expected, got)
+		}
+		return nil
+	})
+}
+
+func TestAccVirtualMachine_macAddress(t *testing.T) {
+	f := testAccSetup(t)
+
+	config := f.virtualMachineConfig("test-vm", nil, f.networkID, f.otherNetworkID)
+	networkInterfaces := func(first, second map[string]interface{}) string {
+		return f.resourceConfig("xenorchestra_virtual_machine", withConfig(withConfig(config, "network_interface", []interface{}{first, second}), "allow_stopping_for_update", true))
+	}
+
+	var generatedMAC string
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy:      f.checkDestroyed("xenorchestra_virtual_machine"),
+		Steps: []resource.TestStep{
+			{
+				Config: networkInterfaces(
+					map[string]interface{}{"network_id": f.networkID, "mac_address": "01:00:5e:00:00:01"},
+					map[string]interface{}{"network_id": f.otherNetworkID},
+				),
+				ExpectError: regexp.MustCompile("unicast MAC address"),
+			},
+			{
+				Config: networkInterfaces(
+					map[string]interface{}{"network_id": f.networkID, "mac_address": "02:00:00:aa:bb:cc"},
+					map[string]interface{}{"network_id": f.otherNetworkID},
+				),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "network_interface.0.mac_address", "02:00:00:aa:bb:cc"),
+					resource.TestCheckResourceAttrSet(testAccVirtualMachine, "network_interface.1.mac_address"),
+					testAccCheckInstance(testAccVirtualMachine, func(is *terraform.InstanceState) error {
+						generatedMAC = is.Attributes["network_interface.1.mac_address"]
+						return nil
+					}),
+					f.checkMACs(testAccVirtualMachine, func() map[string]string {
+						return map[string]string{f.networkID: "02:00:00:aa:bb:cc", f.otherNetworkID: generatedMAC}
+					}),
+				),
+			},
+			{
+				// swapping the networks keeps the MAC of each interface, configured or generated
+				Config: networkInterfaces(
+					map[string]interface{}{"network_id": f.otherNetworkID, "mac_address": "02:00:00:aa:bb:cc"},
+					map[string]interface{}{"network_id": f.networkID},
+				),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "network_interface.0.mac_address", "02:00:00:aa:bb:cc"),
+					f.checkMACs(testAccVirtualMachine, func() map[string]string {
+						return map[string]string{f.otherNetworkID: "02:00:00:aa:bb:cc", f.networkID: generatedMAC}
+					}),
+				),
+			},
+			{
+				// MACs are compared case insensitively
+				Config: networkInterfaces(
+					map[string]interface{}{"network_id": f.otherNetworkID, "mac_address": "02:00:00:AA:BB:CC"},
+					map[string]interface{}{"network_id": f.networkID},
+				),
+				PlanOnly: true,
+			},
+		},
+	})
+}
+
+// checkVIF checks the VIF on networkID of the VM of the resource name
+func (f *testAccFixtures) checkVIF(name, networkID string, check func(vif map[string]interface{}) error) resource.TestCheckFunc {
+	return f.checkVirtualMachine(name, func(vm map[string]interface{}) error {
+		for _, vifID := range vm["VIFs"].([]interface{}) {
+			vif := f.server.Object(vifID.(string))
+			if vif != nil && vif["$network"] == networkID {
+				return check(vif)
+			}
+		}
+		return fmt.Errorf("no VIF on network %s", networkID)
+	})
+}
+
+func TestAccVirtualMachine_networkInterfaceSettings(t *testing.T) {
+	f := testAccSetup(t)
+
+	config := f.virtualMachineConfig("test-vm", nil, f.networkID)
+	withInterface := func(networkInterface map[string]interface{}) map[string]interface{} {
+		networkInterface["network_id"] = f.networkID
+		return withConfig(config, "network_interface", []interface{}{networkInterface})
+	}
+
+	var vifID, mac string
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy:      f.checkDestroyed("xenorchestra_virtual_machine"),
+		Steps: []resource.TestStep{
+			{
+				Config:      f.resourceConfig("xenorchestra_virtual_machine", withInterface(map[string]interface{}{"locking_mode": "open"})),
+				ExpectError: regexp.MustCompile("locking_mode"),
+			},
+			{
+				Config: f.resourceConfig("xenorchestra_virtual_machine", withInterface(map[string]interface{}{
+					"locking_mode":           "locked",
+					"allowed_ipv4_addresses": []interface{}{"10.0.0.10", "10.0.0.11"},
+					"rate_limit_kbps":        1024,
+					"mtu":                    9000,
+				})),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "network_interface.0.locking_mode", "locked"),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "network_interface.0.allowed_ipv4_addresses.#", "2"),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "network_interface.0.allowed_ipv6_addresses.#", "0"),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "network_interface.0.rate_limit_kbps", "1024"),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "network_interface.0.mtu", "9000"),
+					f.checkVIF(testAccVirtualMachine, f.networkID, func(vif map[string]interface{}) error {
+						vifID = vif["id"].(string)
+						mac = vif["MAC"].(string)
+						if vif["lockingMode"] != "locked" || vif["rateLimit"] != float64(1024) || vif["MTU"] != float64(9000) || len(vif["allowedIpv4Addresses"].([]interface{})) != 2 {
+							return fmt.Errorf("VIF settings not applied: %v", vif)
+						}
+						return nil
+					}),
+				),
+			},
+			{
+				// settings are changed on the running VM without recreating the VIF
+				Config: f.resourceConfig("xenorchestra_virtual_machine", withInterface(map[string]interface{}{
+					"locking_mode":           "locked",
+					"allowed_ipv4_addresses": []interface{}{"10.0.0.10"},
+					"allowed_ipv6_addresses": []interface{}{"fd00::10"},
+					"mtu":                    9000,
+				})),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "network_interface.0.allowed_ipv4_addresses.#", "1"),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "network_interface.0.allowed_ipv6_addresses.#", "1"),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "network_interface.0.rate_limit_kbps", "0"),
+					f.checkPowerState(testAccVirtualMachine, "Running"),
+					f.checkStops(0),
+					f.checkVIF(testAccVirtualMachine, f.networkID, func(vif map[string]interface{}) error {
+						if vif["id"] != vifID {
+							return fmt.Errorf("expected VIF %s to be kept, got %s", vifID, vif["id"])
+						}
+						if _, ok := vif["rateLimit"]; ok || len(vif["allowedIpv6Addresses"].([]interface{})) != 1 {
+							return fmt.Errorf("VIF settings not applied: %v", vif)
+						}
+						return nil
+					}),
+				),
+			},
+			{
+				// changing the MTU recreates the VIF with the same MAC
+				Config: f.resourceConfig("xenorchestra_virtual_machine", withConfig(withInterface(map[string]interface{}{
+					"locking_mode":           "locked",
+					"allowed_ipv4_addresses": []interface{}{"10.0.0.10"},
+					"allowed_ipv6_addresses": []interface{}{"fd00::10"},
+					"mtu":                    1500,
+				}), "allow_stopping_for_update", true)),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "network_interface.0.locking_mode", "locked"),
+					f.checkVIF(testAccVirtualMachine, f.networkID, func(vif map[string]interface{}) error {
+						if vif["id"] == vifID {
+							return fmt.Errorf("expected VIF %s to be recreated", vifID)
+						}
+						if vif["MAC"] != mac {
+							return fmt.Errorf("expected MAC %s to be kept, got %v", mac, vif["MAC"])
+						}
+						if vif["lockingMode"] != "locked" || vif["MTU"] != float64(1500) {
+							return fmt.Errorf("VIF settings not applied: %v", vif)
+						}
+						return nil
+					}),
+				),
+			},
+		},
+	})
+}
+
+func TestAccVirtualMachine_stoppingForUpdate(t *testing.T) {
+	f := testAccSetup(t)
+
+	disk := f.server.AddVDI(f.storageRepositoryID, "disk", 1*testAccGiB)
+	config := f.virtualMachineConfig("test-vm", nil, f.networkID)
+	updated := f.virtualMachineConfig("test-vm", []string{disk}, f.networkID, f.otherNetworkID)
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy:      f.checkDestroyed("xenorchestra_virtual_machine"),
+		Steps: []resource.TestStep{
+			{
+				Config: f.resourceConfig("xenorchestra_virtual_machine", config),
+				Check:  f.checkPowerState(testAccVirtualMachine, "Running"),
+			},
+			{
+				// without PV drivers the VM has to be stopped to change devices
+				Config:      f.resourceConfig("xenorchestra_virtual_machine", updated),
+				ExpectError: regexp.MustCompile("allow_stopping_for_update"),
+			},
+			{
+				Config: f.resourceConfig("xenorchestra_virtual_machine", withConfig(updated, "allow_stopping_for_update", true)),
+				Check: resource.ComposeTestCheckFunc(
+					f.checkAttachedDisks(testAccVirtualMachine, disk),
+					f.checkNetworks(testAccVirtualMachine, f.networkID, f.otherNetworkID),
+					f.checkPowerState(testAccVirtualMachine, "Running"),
+					func(s *terraform.State) error {
+						stops := f.server.Calls("vm.stop")
+						if len(stops) != 1 || stops[0].Params["force"] != true {
+							return fmt.Errorf("expected one forced vm.stop, got %v", stops)
+						}
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+// checkCPUs checks the number of vCPUs and the max vCPUs of the VM of the resource name
+func (f *testAccFixtures) checkCPUs(name string, number, max int) resource.TestCheckFunc {
+	return f.checkVirtualMachine(name, func(vm map[string]interface{}) error {
+		cpus := vm["CPUs"].(map[string]interface{})
+		if cpus["number"] != float64(number) || cpus["max"] != float64(max) {
+			return fmt.Errorf("expected %d of %d vCPUs, got %v", number, max, cpus)
+		}
+		return nil
+	})
+}
+
+// checkStops checks how many times VMs were stopped
+func (f *testAccFixtures) checkStops(count int) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		if stops := f.server.Calls("vm.stop"); len(stops) != count {
+			return fmt.Errorf("expected %d vm.stop calls, got %d", count, len(stops))
+		}
+		return nil
+	}
+}
+
+func TestAccVirtualMachine_cpus(t *testing.T) {
+	f := testAccSetup(t)
+
+	config := f.virtualMachineConfig("test-vm", nil, f.networkID)
+
+	var vmID string
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy:      f.checkDestroyed("xenorchestra_virtual_machine"),
+		Steps: []resource.TestStep{
+			{
+				Config:      f.resourceConfig("xenorchestra_virtual_machine", withConfig(config, "max_cpus", 1)),
+				ExpectError: regexp.MustCompile(`cpus \(2\) cannot be greater than max_cpus \(1\)`),
+			},
+			{
+				Config: f.resourceConfig("xenorchestra_virtual_machine", withConfig(config, "max_cpus", 4)),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "cpus", "2"),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "max_cpus", "4"),
+					f.checkCPUs(testAccVirtualMachine, 2, 4),
+					f.checkPowerState(testAccVirtualMachine, "Running"),
+					testAccStoreID(testAccVirtualMachine, &vmID),
+				),
+			},
+			{
+				// hot added up to max_cpus while running
+				Config: f.resourceConfig("xenorchestra_virtual_machine", withConfig(withConfig(config, "max_cpus", 4), "cpus", 4)),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckSameID(testAccVirtualMachine, &vmID),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "cpus", "4"),
+					f.checkCPUs(testAccVirtualMachine, 4, 4),
+					f.checkStops(0),
+				),
+			},
+			{
+				// a configured max is never raised along with cpus
+				Config:      f.resourceConfig("xenorchestra_virtual_machine", withConfig(withConfig(withConfig(config, "max_cpus", 4), "cpus", 6), "allow_stopping_for_update", true)),
+				ExpectError: regexp.MustCompile(`cpus \(6\) cannot be greater than max_cpus \(4\)`),
+			},
+			{
+				// going beyond the max needs the VM stopped
+				Config:      f.resourceConfig("xenorchestra_virtual_machine", withConfig(withConfig(config, "max_cpus", 8), "cpus", 6)),
+				ExpectError: regexp.MustCompile("Cannot change max_cpus from 4 to 8 (.|\n)*allow_stopping_for_update"),
+			},
+			{
+				Config: f.resourceConfig("xenorchestra_virtual_machine", withConfig(withConfig(withConfig(config, "max_cpus", 8), "cpus", 6), "allow_stopping_for_update", true)),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckSameID(testAccVirtualMachine, &vmID),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "cpus", "6"),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "max_cpus", "8"),
+					f.checkCPUs(testAccVirtualMachine, 6, 8),
+					f.checkPowerState(testAccVirtualMachine, "Running"),
+					f.checkStops(1),
+				),
+			},
+			{
+				// without max_cpus the max of the VM is raised along with cpus
+				Config: f.resourceConfig("xenorchestra_virtual_machine", withConfig(withConfig(config, "cpus", 10), "allow_stopping_for_update", true)),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckSameID(testAccVirtualMachine, &vmID),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "cpus", "10"),
+					f.checkCPUs(testAccVirtualMachine, 10, 10),
+					f.checkStops(2),
+				),
+			},
+		},
+	})
+}
+
+// checkMemory checks the static max and dynamic range in GiB of the VM of the resource name
+func (f *testAccFixtures) checkMemory(name string, staticMax, dynamicMin, dynamicMax int) resource.TestCheckFunc {
+	return f.checkVirtualMachine(name, func(vm map[string]interface{}) error {
+		memory := vm["memory"].(map[string]interface{})
+		static := memory["static"].([]interface{})
+		dynamic := memory["dynamic"].([]interface{})
+		if static[1] != float64(staticMax*testAccGiB) || dynamic[0] != float64(dynamicMin*testAccGiB) || dynamic[1] != float64(dynamicMax*testAccGiB) {
+			return fmt.Errorf("expected %d GiB with a dynamic range of %d-%d GiB, got %v", staticMax, dynamicMin, dynamicMax, memory)
+		}
+		return nil
+	})
+}
+
+func TestAccVirtualMachine_memory(t *testing.T) {
+	f := testAccSetup(t)
+
+	config := f.virtualMachineConfig("test-vm", nil, f.networkID)
+	ballooning := withConfig(config, "memory_dynamic_min", 2)
+
+	staticMaxOnly := withConfig(ballooning, "memory_static_max", 8)
+	delete(staticMaxOnly, "memory")
+
+	var vmID string
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy:      f.checkDestroyed("xenorchestra_virtual_machine"),
+		Steps: []resource.TestStep{
+			{
+				Config:      f.resourceConfig("xenorchestra_virtual_machine", withConfig(config, "memory_static_max", 4)),
+				ExpectError: regexp.MustCompile("only one of `memory,memory_static_max` can be specified"),
+			},
+			{
+				Config: f.resourceConfig("xenorchestra_virtual_machine", config),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "memory", "4"),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "memory_static_max", "4"),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "memory_dynamic_min", "4"),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "memory_dynamic_max", "4"),
+					f.checkMemory(testAccVirtualMachine, 4, 4, 4),
+					testAccStoreID(testAccVirtualMachine, &vmID),
+				),
+			},
+			{
+				// the dynamic range changes live
+				Config: f.resourceConfig("xenorchestra_virtual_machine", ballooning),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckSameID(testAccVirtualMachine, &vmID),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "memory_dynamic_min", "2"),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "memory_dynamic_max", "4"),
+					f.checkMemory(testAccVirtualMachine, 4, 2, 4),
+					f.checkStops(0),
+				),
+			},
+			{
+				Config:      f.resourceConfig("xenorchestra_virtual_machine", withConfig(ballooning, "memory_dynamic_max", 6)),
+				ExpectError: regexp.MustCompile(`memory_dynamic_max \(6\) cannot be greater than memory_static_max \(4\)`),
+			},
+			{
+				Config:      f.resourceConfig("xenorchestra_virtual_machine", withConfig(ballooning, "memory", 8)),
+				ExpectError: regexp.MustCompile("Cannot change memory_static_max (.|\n)*allow_stopping_for_update"),
+			},
+			{
+				// the dynamic max follows the static max it was pinned to
+				Config: f.resourceConfig("xenorchestra_virtual_machine", withConfig(withConfig(ballooning, "memory", 8), "allow_stopping_for_update", true)),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckSameID(testAccVirtualMachine, &vmID),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "memory", "8"),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "memory_static_max", "8"),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "memory_dynamic_min", "2"),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "memory_dynamic_max", "8"),
+					f.checkMemory(testAccVirtualMachine, 8, 2, 8),
+					f.checkPowerState(testAccVirtualMachine, "Running"),
+					f.checkStops(1),
+				),
+			},
+			{
+				Config:   f.resourceConfig("xenorchestra_virtual_machine", withConfig(staticMaxOnly, "allow_stopping_for_update", true)),
+				PlanOnly: true,
+			},
+		},
+	})
+}
+
+func TestAccVirtualMachine_memoryBallooningOnCreate(t *testing.T) {
+	f := testAccSetup(t)
+
+	config := f.virtualMachineConfig("test-vm", nil, f.networkID)
+	delete(config, "memory")
+	config["memory_static_max"] = 8
+	config["memory_dynamic_max"] = 4
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy:      f.checkDestroyed("xenorchestra_virtual_machine"),
+		Steps: []resource.TestStep{
+			{
+				Config: f.resourceConfig("xenorchestra_virtual_machine", config),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "memory", "8"),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "memory_dynamic_min", "4"),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "memory_dynamic_max", "4"),
+					f.checkMemory(testAccVirtualMachine, 8, 4, 4),
+					f.checkPowerState(testAccVirtualMachine, "Running"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccVirtualMachine_memoryStaticMin(t *testing.T) {
+	f := testAccSetup(t)
+
+	config := f.virtualMachineConfig("test-vm", nil, f.networkID)
+
+	var vmID string
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy:      f.checkDestroyed("xenorchestra_virtual_machine"),
+		Steps: []resource.TestStep{
+			{
+				Config: f.resourceConfig("xenorchestra_virtual_machine", config),
+				Check:  testAccStoreID(testAccVirtualMachine, &vmID),
+			},
+			{
+				// a template with a static min of 2 GiB
+				PreConfig: func() {
+					f.server.UpdateObject(vmID, map[string]interface{}{
+						"memory": map[string]interface{}{
+							"size":    float64(4 * testAccGiB),
+							"static":  []interface{}{float64(2 * testAccGiB), float64(4 * testAccGiB)},
+							"dynamic": []interface{}{float64(4 * testAccGiB), float64(4 * testAccGiB)},
+						},
+					})
+				},
+				Config: f.resourceConfig("xenorchestra_virtual_machine", config),
+				Check:  resource.TestCheckResourceAttr(testAccVirtualMachine, "memory_static_min", "2"),
+			},
+			{
+				Config:      f.resourceConfig("xenorchestra_virtual_machine", withConfig(config, "memory_dynamic_min", 1)),
+				ExpectError: regexp.MustCompile(`memory_dynamic_min \(1\) cannot be less than memory_static_min \(2\)`),
+			},
+			{
+				Config: f.resourceConfig("xenorchestra_virtual_machine", withConfig(config, "memory_dynamic_min", 2)),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckSameID(testAccVirtualMachine, &vmID),
+					f.checkMemory(testAccVirtualMachine, 4, 2, 4),
+				),
+			},
+		},
+	})
+}
+
+func TestAccVirtualMachine_desiredStatus(t *testing.T) {
+	f := testAccSetup(t)
+
+	config := f.virtualMachineConfig("test-vm", nil, f.networkID)
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy:      f.checkDestroyed("xenorchestra_virtual_machine"),
+		Steps: []resource.TestStep{
+			{
+				Config:      f.resourceConfig("xenorchestra_virtual_machine", withConfig(config, "desired_status", "Halted")),
+				ExpectError: regexp.MustCompile("desired_status can only accept Running"),
+			},
+			{
+				Config: f.resourceConfig("xenorchestra_virtual_machine", withConfig(config, "desired_status", "Running")),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "desired_status", "Running"),
+					f.checkPowerState(testAccVirtualMachine, "Running"),
+				),
+			},
+			{
+				Config: f.resourceConfig("xenorchestra_virtual_machine", withConfig(config, "desired_status", "Halted")),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "desired_status", "Halted"),
+					f.checkPowerState(testAccVirtualMachine, "Halted"),
+				),
+			},
+			{
+				Config: f.resourceConfig("xenorchestra_virtual_machine", withConfig(config, "desired_status", "Running")),
+				Check:  f.checkPowerState(testAccVirtualMachine, "Running"),
+			},
+		},
+	})
+}
+
+func TestAccVirtualMachine_drift(t *testing.T) {
+	f := testAccSetup(t)
+
+	config := f.resourceConfig("xenorchestra_virtual_machine", withConfig(f.virtualMachineConfig("test-vm", nil, f.networkID), "desired_status", "Running"))
+
+	var vmID string
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy:      f.checkDestroyed("xenorchestra_virtual_machine"),
+		Steps: []resource.TestStep{
+			{
+				Config: config,
+				Check:  testAccStoreID(testAccVirtualMachine, &vmID),
+			},
+			{
+				PreConfig: func() {
+					f.server.UpdateObject(vmID, map[string]interface{}{"name_label": "renamed-out-of-band"})
+				},
+				Config:             config,
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+			{
+				Config: config,
+				Check: f.checkVirtualMachine(testAccVirtualMachine, func(vm map[string]interface{}) error {
+					if vm["name_label"] != "test-vm" {
+						return fmt.Errorf("name was not restored, got %v", vm["name_label"])
+					}
+					return nil
+				}),
+			},
+			{
+				PreConfig: func() {
+					f.server.UpdateObject(vmID, map[string]interface{}{"power_state": "Halted"})
+				},
+				Config: config,
+				Check:  f.checkPowerState(testAccVirtualMachine, "Running"),
+			},
+			{
+				PreConfig: func() {
+					f.server.DeleteObject(vmID)
+				},
+				Config:             config,
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+			{
+				Config: config,
+				Check:  testAccCheckNewID(testAccVirtualMachine, &vmID),
+			},
+		},
+	})
+}
+
+// detachOutOfBand deletes a VBD or VIF outside of terraform
+func (f *testAccFixtures) detachOutOfBand(t *testing.T, vbdID, vifID string) {
+	t.Helper()
+
+	ctx := context.Background()
+	_, meta := f.testAccProvider(t)
+	c := meta.(*providerMeta).client
+
+	if len(vbdID) > 0 {
+		vbd, err := c.GetVBDByID(ctx, vbdID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if vbd.Attached {
+			if err := vbd.Disconnect(c, ctx); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := vbd.Delete(c, ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(vifID) > 0 {
+		vif, err := c.GetVIFByID(ctx, vifID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if vif.Attached {
+			if err := vif.Disconnect(c, ctx); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := vif.Delete(c, ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAccVirtualMachine_attachmentOwnership(t *testing.T) {
+	f := testAccSetup(t)
+	f.server.SetPVDriversDetected(true)
+
+	ownDisk := f.server.AddVDI(f.storageRepositoryID, "own", 2*testAccGiB)
+	foreignDisk := f.server.AddVDI(f.storageRepositoryID, "foreign", 2*testAccGiB)
+
+	config := f.resourceConfig("xenorchestra_virtual_machine", f.virtualMachineConfig("test-vm", []string{ownDisk}, f.networkID))
+
+	var vmID, vbdID, vifID string
+	storeAttachments := func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[testAccVirtualMachine]
+		if !ok {
+			return fmt.Errorf("resource %s not found", testAccVirtualMachine)
+		}
+		vmID = rs.Primary.ID
+		vbdID = rs.Primary.Attributes["attached_disk.0.vbd_id"]
+		vifID = rs.Primary.Attributes["network_interface.0.vif_id"]
+		if len(vbdID) == 0 || len(vifID) == 0 {
+			return fmt.Errorf("expected vbd_id and vif_id to be recorded, got %q and %q", vbdID, vifID)
+		}
+		return nil
+	}
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy:      f.checkDestroyed("xenorchestra_virtual_machine"),
+		Steps: []resource.TestStep{
+			{
+				Config: config,
+				Check:  storeAttachments,
+			},
+			{
+				// attachments made outside of terraform are not owned
+				PreConfig: func() {
+					f.attachDisk(t, vmID, foreignDisk)
+					f.attachNetwork(t, vmID, f.otherNetworkID)
+				},
+				Config:   config,
+				PlanOnly: true,
+			},
+			{
+				// owned attachments that go missing are drift
+				PreConfig: func() {
+					f.detachOutOfBand(t, vbdID, vifID)
+				},
+				Config:             config,
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+			{
+				Config: config,
+				Check: resource.ComposeTestCheckFunc(
+					f.checkAttachedDisks(testAccVirtualMachine, ownDisk, foreignDisk),
+					f.checkNetworks(testAccVirtualMachine, f.networkID, f.otherNetworkID),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "attached_disk.#", "1"),
+					resource.TestCheckResourceAttr(testAccVirtualMachine, "network_interface.#", "1"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccVirtualMachine_timeouts(t *testing.T) {
+	f := testAccSetup(t)
+
+	config := withConfig(f.virtualMachineConfig("test-vm", nil, f.networkID), "timeouts", map[string]interface{}{
+		"update": "500ms",
+	})
+
+	var vmID string
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy:      f.checkDestroyed("xenorchestra_virtual_machine"),
+		Steps: []resource.TestStep{
+			{
+				// XO takes longer to answer than the create timeout
+				PreConfig: func() {
+					f.server.InjectFault(xotest.Fault{Method: "vm.create", Times: 1, Delay: time.Second})
+				},
+				Config: f.resourceConfig("xenorchestra_virtual_machine", withConfig(config, "timeouts", map[string]interface{}{
+					"create": "100ms",
+				})),
+				ExpectError: regexp.MustCompile("vm.create was still pending"),
+			},
+			{
+				Config: f.resourceConfig("xenorchestra_virtual_machine", config),
+				Check:  testAccStoreID(testAccVirtualMachine, &vmID),
+			},
+			{
+				// the first retry is due after the update timeout
+				PreConfig: func() {
+					f.server.InjectFault(xotest.Fault{Method: "vm.set", Times: 1, Err: xotest.XapiError("OTHER_OPERATION_IN_PROGRESS", "VM", vmID)})
+				},
+				Config:      f.resourceConfig("xenorchestra_virtual_machine", withConfig(config, "name", "renamed")),
+				ExpectError: regexp.MustCompile("vm.set was still being retried"),
+			},
+			{
+				Config: f.resourceConfig("xenorchestra_virtual_machine", withConfig(config, "name", "renamed")),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckSameID(testAccVirtualMachine, &vmID),
+					f.checkVirtualMachine(testAccVirtualMachine, func(vm map[string]interface{}) error {
+						if vm["name_label"] != "renamed" {
+							return fmt.Errorf("VM not renamed: %v", vm["name_label"])
+						}
+						return nil
+					}),
+				),
+			},
+		},
+	})
+}
+
+// createVirtualMachine creates a VM from the template outside of terraform, like the VMs that
+// existed before it was used
+func (f *testAccFixtures) createVirtualMachine(t *testing.T, name string, diskIDs []string, networkIDs ...string) string {
+	t.Helper()
+
+	ctx := context.Background()
+	_, meta := f.testAccProvider(t)
+	c := meta.(*providerMeta).client
+
+	template, err := c.GetTemplateByID(ctx, f.templateID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var vifs []xo_client.VirtualMachineVIF
+	for _, networkID := range networkIDs {
+		vifs = append(vifs, xo_client.VirtualMachineVIF{NetworkID: networkID})
+	}
+
+	vm, err := c.CreateVirtualMachine(ctx, name, "", template, 2, 4*testAccGiB, nil, nil, nil, vifs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, diskID := range diskIDs {
+		vdi, err := c.GetVDIByID(ctx, diskID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := vm.AttachDisk(c, ctx, vdi, "", false, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return vm.ID
+}
+
+// testAccCheckImported runs check against the single instance imported
+func testAccCheckImported(check func(is *terraform.InstanceState) error) resource.ImportStateCheckFunc {
+	return func(states []*terraform.InstanceState) error {
+		if len(states) != 1 {
+			return fmt.Errorf("expected 1 imported instance, got %d", len(states))
+		}
+		return check(states[0])
+	}
+}
+
+// testAccCheckImportedAttrs checks the attributes of the single instance imported
+func testAccCheckImportedAttrs(attrs map[string]string) resource.ImportStateCheckFunc {
+	return testAccCheckImported(func(is *terraform.InstanceState) error {
+		for k, v := range attrs {
+			if got := is.Attributes[k]; got != v {
+				return fmt.Errorf("%s: expected %q, got %q", k, v, got)
+			}
+		}
+		return nil
+	})
+}
+
+func TestAccVirtualMachine_import(t *testing.T) {
+	f := testAccSetup(t)
+
+	diskID := f.server.AddVDI(f.storageRepositoryID, "data", 2*testAccGiB)
+	legacyDiskID := f.server.AddVDI(f.storageRepositoryID, "legacy-data", 2*testAccGiB)
+	legacyVMID := f.createVirtualMachine(t, "legacy-vm", []string{legacyDiskID}, f.networkID)
+
+	config := f.resourceConfig("xenorchestra_virtual_machine", withConfig(f.virtualMachineConfig("test-vm", []string{diskID}, f.networkID), "description", "adopted"))
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy:      f.checkDestroyed("xenorchestra_virtual_machine"),
+		Steps: []resource.TestStep{
+			{
+				Config: config,
+			},
+			{
+				ResourceName:      testAccVirtualMachine,
+				ImportState:       true,
+				ImportStateVerify: true,
+				// settings of terraform itself, not of the VM
+				ImportStateVerifyIgnore: []string{"allow_stopping_for_update", "desired_status"},
+			},
+			{
+				// a VM that predates terraform
+				ResourceName:  testAccVirtualMachine,
+				ImportState:   true,
+				ImportStateId: legacyVMID,
+				ImportStateCheck: testAccCheckImportedAttrs(map[string]string{
+					"id":                             legacyVMID,
+					"name":                           "legacy-vm",
+					"template_id":                    f.templateID,
+					"cpus":                           "2",
+					"memory":                         "4",
+					"boot_disk.0.size":               "10",
+					"attached_disk.#":                "1",
+					"attached_disk.0.disk_id":        legacyDiskID,
+					"network_interface.#":            "1",
+					"network_interface.0.network_id": f.networkID,
+				}),
+			},
+		},
+	})
+}
+
+func TestAccVirtualMachine_importWithoutTemplate(t *testing.T) {
+	f := testAccSetup(t)
+
+	vmID := f.createVirtualMachine(t, "legacy-vm", nil, f.networkID)
+
+	// the template was renamed since the VM was created from it
+	f.server.UpdateObject(f.templateID, map[string]interface{}{"name_label": "Debian Buster 10 (old)"})
+
+	config := f.virtualMachineConfig("legacy-vm", nil, f.networkID)
+	config["installation"] = []interface{}{
+		map[string]interface{}{
+			"method": "network",
+		},
+	}
+
+	var imported *terraform.InstanceState
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				// template_id and installation can't be recovered, but the rest of the VM is imported
+				ResourceName:  testAccVirtualMachine,
+				Config:        f.resourceConfig("xenorchestra_virtual_machine", config),
+				ImportState:   true,
+				ImportStateId: vmID,
+				ImportStateCheck: testAccCheckImported(func(is *terraform.InstanceState) error {
+					imported = is
+					if is.ID != vmID || is.Attributes["template_id"] != "" || is.Attributes["installation.#"] != "0" {
+						return fmt.Errorf("unexpected imported VM: %v", is.Attributes)
+					}
+					return nil
+				}),
+			},
+		},
+	})
+
+	ctx := context.Background()
+	_, meta := f.testAccProvider(t)
+	r := resourceVirtualMachine()
+
+	// filling them in doesn't replace the VM
+	diff, err := r.Diff(ctx, imported, terraform.NewResourceConfigRaw(config), meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.RequiresNew() {
+		t.Fatalf("expected the imported VM to be kept, got %v", diff)
+	}
+
+	// once they are known changing them replaces the VM again
+	known := imported.DeepCopy()
+	known.Attributes["template_id"] = f.templateID
+	diff, err = r.Diff(ctx, known, terraform.NewResourceConfigRaw(withConfig(config, "template_id", f.emptyTemplateID)), meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.RequiresNew() {
+		t.Fatalf("expected the VM to be replaced, got %v", diff)
+	}
+}
@@ -0,0 +1,290 @@
+package xo_client
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// objectCache mirrors the XO object collection in memory. It is seeded by a single
+// xo.getAllObjects call and then kept current from the notifications the server pushes
+// to every signed in connection.
+type objectCache struct {
+	mu      sync.RWMutex
+	loaded  bool
+	seeding bool
+	objects map[string]cachedObject
+	// ids that changed while a seed was in flight, the seed must not overwrite them
+	touched map[string]struct{}
+	// bumped on every invalidation so a seed that raced with one is thrown away
+	generation uint64
+	// objects a call changed whose notification hasn't arrived yet, lookups that could
+	// return them go to the server
+	stale map[string]staleObject
+
+	loadMu sync.Mutex
+}
+
+// cachedObject keeps the raw JSON for decoding into typed structs next to the generic value
+// filters are matched against
+type cachedObject struct {
+	raw   json.RawMessage
+	value map[string]interface{}
+}
+
+type staleObject struct {
+	apiType string
+	expires time.Time
+}
+
+// staleTimeout bounds how long a change waits for its notification, calls that end up not
+// changing an object never get one
+const staleTimeout = 30 * time.Second
+
+type objectsNotification struct {
+	Type  string                     `json:"type"`
+	Items map[string]json.RawMessage `json:"items"`
+}
+
+func newObjectCache() *objectCache {
+	return &objectCache{
+		objects: map[string]cachedObject{},
+		stale:   map[string]staleObject{},
+	}
+}
+
+func newCachedObject(raw json.RawMessage) (cachedObject, error) {
+	obj := cachedObject{raw: raw}
+	err := json.Unmarshal(raw, &obj.value)
+	return obj, err
+}
+
+func (oc *objectCache) load(ctx context.Context, c *Client) error {
+	oc.mu.RLock()
+	loaded := oc.loaded
+	oc.mu.RUnlock()
+	if loaded {
+		return nil
+	}
+
+	oc.loadMu.Lock()
+	defer oc.loadMu.Unlock()
+
+	oc.mu.Lock()
+	if oc.loaded {
+		oc.mu.Unlock()
+		return nil
+	}
+	oc.seeding = true
+	oc.touched = map[string]struct{}{}
+	generation := oc.generation
+	oc.mu.Unlock()
+
+	objs := map[string]cachedObject{}
+	raws := Objects{}
+	err := c.call(ctx, "xo.getAllObjects", map[string]interface{}{}, &raws)
+	if err == nil {
+		for id, raw := range raws {
+			objs[id], err = newCachedObject(raw)
+			if err != nil {
+				break
+			}
+		}
+	}
+
+	oc.mu.Lock()
+	defer oc.mu.Unlock()
+
+	oc.seeding = false
+	if err != nil {
+		oc.touched = nil
+		return err
+	}
+
+	for id := range oc.touched {
+		if generation != oc.generation {
+			break
+		}
+
+		if obj, ok := oc.objects[id]; ok {
+			objs[id] = obj
+		} else {
+			delete(objs, id)
+		}
+	}
+	oc.objects = objs
+	oc.touched = nil
+
+	// when invalidated while seeding the result is only good for the lookup that triggered it
+	oc.loaded = generation == oc.generation
+
+	log.Printf("[DEBUG] xo_client: object cache seeded with %d objects", len(oc.objects))
+
+	return nil
+}
+
+// invalidate drops everything so the next lookup seeds the cache again
+func (oc *objectCache) invalidate() {
+	oc.mu.Lock()
+	defer oc.mu.Unlock()
+
+	oc.loaded = false
+	oc.generation++
+	oc.objects = map[string]cachedObject{}
+	oc.stale = map[string]staleObject{}
+}
+
+// markStale flags the cached objects named in the params of a call, and the objects they
+// link to, until the notification of their change arrives
+func (oc *objectCache) markStale(params interface{}) {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return
+	}
+	var value interface{}
+	if err := json.Unmarshal(b, &value); err != nil {
+		return
+	}
+
+	oc.mu.Lock()
+	defer oc.mu.Unlock()
+
+	// find only skips expired entries, calls that never get a notification would leave them
+	// behind for good
+	now := time.Now()
+	for id, stale := range oc.stale {
+		if now.After(stale.expires) {
+			delete(oc.stale, id)
+		}
+	}
+
+	expires := now.Add(staleTimeout)
+	mark := func(id string) bool {
+		obj, ok := oc.objects[id]
+		if !ok {
+			return false
+		}
+		apiType, _ := obj.value["type"].(string)
+		oc.stale[id] = staleObject{apiType: apiType, expires: expires}
+		return true
+	}
+
+	for _, id := range objectIDs(value) {
+		if !mark(id) {
+			continue
+		}
+
+		// VBDs, VIFs and the like are listed on both ends
+		for _, linked := range objectIDs(oc.objects[id].value) {
+			mark(linked)
+		}
+	}
+}
+
+// objectIDs collects the strings in value that may be object IDs, the caller checks them
+// against the cache
+func objectIDs(value interface{}) []string {
+	var ids []string
+	switch v := value.(type) {
+	case string:
+		ids = append(ids, v)
+	case []interface{}:
+		for _, item := range v {
+			ids = append(ids, objectIDs(item)...)
+		}
+	case map[string]interface{}:
+		for _, item := range v {
+			ids = append(ids, objectIDs(item)...)
+		}
+	}
+	return ids
+}
+
+// find returns the cached objects of apiType matching query, ok is false when a change to
+// one of them may not have been announced yet and the server has to be asked instead
+func (oc *objectCache) find(apiType string, query Filter) (objs Objects, ok bool) {
+	oc.mu.RLock()
+	defer oc.mu.RUnlock()
+
+	now := time.Now()
+	id, isIDQuery := queryID(query)
+	for staleID, stale := range oc.stale {
+		if now.After(stale.expires) {
+			continue
+		}
+		if isIDQuery && staleID == id || !isIDQuery && stale.apiType == apiType {
+			return nil, false
+		}
+	}
+
+	objs = Objects{}
+	for id, obj := range oc.objects {
+		if obj.value["type"] != apiType {
+			continue
+		}
+
+		if query.Match(obj.value) {
+			objs[id] = obj.raw
+		}
+	}
+
+	return objs, true
+}
+
+func (oc *objectCache) apply(notification *objectsNotification) {
+	oc.mu.Lock()
+	defer oc.mu.Unlock()
+
+	if !oc.loaded && !oc.seeding {
+		return
+	}
+
+	for id, raw := range notification.Items {
+		switch notification.Type {
+		case "enter":
+			obj, err := newCachedObject(raw)
+			if err != nil {
+				log.Printf("[WARN] xo_client: unable to decode object %s: %v", id, err)
+				delete(oc.objects, id)
+				continue
+			}
+			oc.objects[id] = obj
+		case "exit":
+			delete(oc.objects, id)
+		default:
+			continue
+		}
+		delete(oc.stale, id)
+
+		if oc.seeding {
+			oc.touched[id] = struct{}{}
+		}
+	}
+}
+
+type notificationHandler struct {
+	cache *objectCache
+}
+
+func (h *notificationHandler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	if h.cache == nil || !req.Notif || req.Params == nil {
+		return
+	}
+
+	if req.Method != "all" && req.Method != "xo.objects" {
+		return
+	}
+
+	notification := &objectsNotification{}
+	err := json.Unmarshal(*req.Params, notification)
+	if err != nil {
+		log.Printf("[WARN] xo_client: unable to decode %s notification: %v", req.Method, err)
+		return
+	}
+
+	h.cache.apply(notification)
+}
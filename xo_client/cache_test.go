@@ -0,0 +1,225 @@
+package xo_client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rmb938/terraform-provider-xenorchestra/xo_client/xotest"
+)
+
+func newCachedClient(t *testing.T, s *xotest.Server) *Client {
+	t.Helper()
+
+	c, err := NewClient(s.URL, WithObjectCache())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.SignIn(context.Background(), "admin", testPassword); err != nil {
+		c.Close()
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+// seedCalls counts the xo.getAllObjects calls without a filter, the ones seeding the cache
+func seedCalls(s *xotest.Server) int {
+	count := 0
+	for _, call := range s.Calls("xo.getAllObjects") {
+		if _, ok := call.Params["filter"]; !ok {
+			count++
+		}
+	}
+	return count
+}
+
+func TestObjectCache(t *testing.T) {
+	s := xotest.NewServer()
+	defer s.Close()
+	s.AddUser("admin", testPassword)
+	pool := s.AddPool("pool")
+	networkID := s.AddNetwork(pool, "Pool-wide network")
+
+	c := newCachedClient(t, s)
+	defer c.Close()
+
+	ctx := context.Background()
+
+	// seeded once by the first lookup
+	for i := 0; i < 2; i++ {
+		if _, err := c.GetNetworkByID(ctx, networkID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls := len(s.Calls("xo.getAllObjects")); calls != 1 {
+		t.Fatalf("expected 1 xo.getAllObjects call, got %d", calls)
+	}
+
+	// kept current from notifications
+	otherID := s.AddNetwork(pool, "Other network")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		objs, ok := c.cache.find("network", ObjectQuery{"id": otherID})
+		if ok && len(objs) == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("network was not added to the cache")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	network, err := c.GetNetworkByName(ctx, pool, "Other network")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if network.ID != otherID {
+		t.Fatalf("expected network %s, got %s", otherID, network.ID)
+	}
+	if calls := len(s.Calls("xo.getAllObjects")); calls != 1 {
+		t.Fatalf("expected 1 xo.getAllObjects call, got %d", calls)
+	}
+
+	c.cache.apply(&objectsNotification{Type: "exit", Items: map[string]json.RawMessage{otherID: nil}})
+	if objs, _ := c.cache.find("network", ObjectQuery{"id": otherID}); len(objs) != 0 {
+		t.Fatalf("expected network to be removed from the cache, got %v", objs)
+	}
+
+	// seeded again after an invalidation
+	c.cache.invalidate()
+	if _, err := c.GetNetworkByID(ctx, otherID); err != nil {
+		t.Fatal(err)
+	}
+	if calls := seedCalls(s); calls != 2 {
+		t.Fatalf("expected 2 seeds, got %d", calls)
+	}
+}
+
+func TestObjectCacheIDMiss(t *testing.T) {
+	s := xotest.NewServer()
+	defer s.Close()
+	s.AddUser("admin", testPassword)
+	pool := s.AddPool("pool")
+	networkID := s.AddNetwork(pool, "Pool-wide network")
+
+	c := newCachedClient(t, s)
+	defer c.Close()
+
+	ctx := context.Background()
+	if _, err := c.GetNetworkByID(ctx, networkID); err != nil {
+		t.Fatal(err)
+	}
+
+	// the network exists but its notification hasn't arrived yet
+	c.cache.apply(&objectsNotification{Type: "exit", Items: map[string]json.RawMessage{networkID: nil}})
+
+	network, err := c.GetNetworkByID(ctx, networkID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if network.ID != networkID {
+		t.Fatalf("expected network %s, got %s", networkID, network.ID)
+	}
+	if calls := len(s.Calls("xo.getAllObjects")); calls != 2 {
+		t.Fatalf("expected the id lookup to go to the server, got %d xo.getAllObjects calls", calls)
+	}
+
+	// only a miss on an id falls back to the server
+	_, err = c.GetNetworkByName(ctx, pool, "Pool-wide network")
+	if !errors.Is(err, NotFoundError) {
+		t.Fatalf("expected NotFoundError, got %v", err)
+	}
+	if calls := len(s.Calls("xo.getAllObjects")); calls != 2 {
+		t.Fatalf("expected the lookup by name to be served from the cache, got %d xo.getAllObjects calls", calls)
+	}
+}
+
+func TestObjectCacheStale(t *testing.T) {
+	s := xotest.NewServer()
+	defer s.Close()
+	s.AddUser("admin", testPassword)
+	pool := s.AddPool("pool")
+	sr := s.AddStorageRepository(pool, "Local storage", "ext")
+	networkID := s.AddNetwork(pool, "Pool-wide network")
+	vdiID := s.AddVDI(sr, "data", 1<<30)
+
+	c := newCachedClient(t, s)
+	defer c.Close()
+
+	ctx := context.Background()
+	vdi, err := c.GetVDIByID(ctx, vdiID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.cache.markStale(map[string]interface{}{"id": vdiID, "name_label": "renamed"})
+
+	// the touched object, the objects it links to and lookups that could return them
+	for _, lookup := range []struct {
+		apiType string
+		query   Filter
+	}{
+		{"VDI", ObjectQuery{"id": vdiID}},
+		{"VDI", ObjectQuery{"name_label": "data"}},
+		{"SR", ObjectQuery{"id": sr}},
+	} {
+		if _, ok := c.cache.find(lookup.apiType, lookup.query); ok {
+			t.Fatalf("expected %s lookup %v to miss the cache", lookup.apiType, lookup.query)
+		}
+	}
+
+	// the rest is still served from the cache
+	if _, ok := c.cache.find("network", ObjectQuery{"id": networkID}); !ok {
+		t.Fatal("expected network lookup to be served from the cache")
+	}
+
+	// until the notification arrives
+	raw, err := json.Marshal(s.Object(vdiID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.cache.apply(&objectsNotification{Type: "enter", Items: map[string]json.RawMessage{vdiID: raw}})
+	if _, ok := c.cache.find("VDI", ObjectQuery{"id": vdiID}); !ok {
+		t.Fatal("expected VDI lookup to be served from the cache after its notification")
+	}
+
+	// or it expires
+	c.cache.mu.Lock()
+	for id, stale := range c.cache.stale {
+		stale.expires = time.Now().Add(-time.Second)
+		c.cache.stale[id] = stale
+	}
+	c.cache.mu.Unlock()
+	if _, ok := c.cache.find("SR", ObjectQuery{"id": sr}); !ok {
+		t.Fatal("expected SR lookup to be served from the cache once expired")
+	}
+
+	// and is dropped by the next call
+	c.cache.markStale(map[string]interface{}{"id": networkID})
+	c.cache.mu.RLock()
+	_, srStale := c.cache.stale[sr]
+	_, networkStale := c.cache.stale[networkID]
+	c.cache.mu.RUnlock()
+	if srStale || !networkStale {
+		t.Fatalf("expected only the network to be stale, got %v", c.cache.stale)
+	}
+
+	// a change made through the client is read back without seeding the cache again
+	name := "renamed"
+	if err := vdi.Update(c, ctx, &name, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	vdi, err = c.GetVDIByID(ctx, vdiID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vdi.Name != name {
+		t.Fatalf("expected VDI to be named %s, got %s", name, vdi.Name)
+	}
+	if calls := seedCalls(s); calls != 1 {
+		t.Fatalf("expected 1 seed, got %d", calls)
+	}
+}
@@ -0,0 +1,179 @@
+package xo_client
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// Interaction is a single JSON-RPC call stored in a cassette
+type Interaction struct {
+	Method string          `json:"method"`
+	Params interface{}     `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	// Error is the error XO responded with
+	Error *jsonrpc2.Error `json:"error,omitempty"`
+	// Failure is set when no response was received, like when the connection dropped
+	Failure string `json:"failure,omitempty"`
+}
+
+// WithRecording appends every call made by the client and the response XO sent back to
+// the cassette at path, one JSON object per line. Credentials are redacted from the params
+// and the responses.
+func WithRecording(path string) ClientOption {
+	return func(c *Client) error {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return fmt.Errorf("unable to open cassette: %w", err)
+		}
+
+		c.recorder = &cassetteRecorder{file: f}
+		return nil
+	}
+}
+
+type cassetteRecorder struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func (r *cassetteRecorder) record(method string, params interface{}, result json.RawMessage, err error) {
+	interaction := Interaction{
+		Method: method,
+		Params: redactParams(params),
+		Result: redactResult(method, result),
+	}
+
+	var rpcErr *jsonrpc2.Error
+	if errors.As(err, &rpcErr) {
+		interaction.Error = rpcErr
+	} else if err != nil {
+		interaction.Failure = err.Error()
+	}
+
+	b, marshalErr := json.Marshal(interaction)
+	if marshalErr != nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// written as we go, the provider never closes the client
+	r.file.Write(append(b, '\n'))
+}
+
+func (r *cassetteRecorder) close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.file.Close()
+}
+
+// NewReplayClient returns a client that answers calls from the cassette at path instead of
+// talking to XO. Calls have to arrive in the recorded order with the recorded params.
+func NewReplayClient(path string, opts ...ClientOption) (*Client, error) {
+	interactions, err := LoadCassette(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		replayer:    &cassetteReplayer{path: path, interactions: interactions},
+		retryPolicy: DefaultRetryPolicy,
+	}
+
+	for _, opt := range opts {
+		err := opt(c)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// LoadCassette reads the interactions recorded in a cassette
+func LoadCassette(path string) ([]Interaction, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open cassette: %w", err)
+	}
+	defer f.Close()
+
+	var interactions []Interaction
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 256*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var interaction Interaction
+		err := json.Unmarshal(scanner.Bytes(), &interaction)
+		if err != nil {
+			return nil, fmt.Errorf("cassette %s line %d: %w", path, line, err)
+		}
+		interactions = append(interactions, interaction)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read cassette: %w", err)
+	}
+
+	return interactions, nil
+}
+
+type cassetteReplayer struct {
+	path string
+
+	mu           sync.Mutex
+	interactions []Interaction
+	next         int
+}
+
+func (r *cassetteReplayer) replay(method string, params, result interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.next >= len(r.interactions) {
+		return fmt.Errorf("cassette %s: unexpected call %s after the last recorded interaction", r.path, method)
+	}
+
+	interaction := r.interactions[r.next]
+	if interaction.Method != method || !reflect.DeepEqual(redactParams(interaction.Params), redactParams(params)) {
+		want, _ := json.Marshal(interaction.Params)
+		got, _ := json.Marshal(redactParams(params))
+		return fmt.Errorf("cassette %s: interaction %d is %s %s, got %s %s", r.path, r.next+1, interaction.Method, want, method, got)
+	}
+	r.next++
+
+	if interaction.Error != nil {
+		return &RPCError{Method: method, Err: interaction.Error}
+	}
+
+	if len(interaction.Failure) > 0 {
+		return errors.New(interaction.Failure)
+	}
+
+	if result == nil || len(interaction.Result) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(interaction.Result, result)
+}
+
+// remaining is the number of recorded interactions that haven't been replayed
+func (r *cassetteReplayer) remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.interactions) - r.next
+}
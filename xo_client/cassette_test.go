@@ -0,0 +1,190 @@
+package xo_client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rmb938/terraform-provider-xenorchestra/xo_client/xotest"
+)
+
+const testPassword = "hunter2"
+
+// recordBootDisk creates a VM against the fake server and looks up its boot disk
+func recordBootDisk(t *testing.T, c *Client) (*VirtualMachine, *VDI) {
+	t.Helper()
+	ctx := context.Background()
+
+	if err := c.SignIn(ctx, "admin", testPassword); err != nil {
+		t.Fatal(err)
+	}
+
+	template, err := c.GetTemplateByName(ctx, poolID(t, c), "Debian Buster 10")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vm, err := c.CreateVirtualMachine(ctx, "vm", "", template, 1, 1<<30, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vdi, err := vm.GetBootDisk(c, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return vm, vdi
+}
+
+func poolID(t *testing.T, c *Client) string {
+	t.Helper()
+
+	pool, err := c.GetPoolByName(context.Background(), "pool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pool.ID
+}
+
+func TestCassetteRecordReplay(t *testing.T) {
+	s := xotest.NewServer()
+	defer s.Close()
+
+	s.AddUser("admin", testPassword)
+	pool := s.AddPool("pool")
+	sr := s.AddStorageRepository(pool, "Local storage", "ext")
+	s.AddTemplate(pool, "Debian Buster 10", xotest.TemplateDisk{StorageRepositoryID: sr, Size: 10 << 30})
+
+	path := filepath.Join(t.TempDir(), "boot_disk.jsonl")
+
+	recording, err := NewClient(s.URL, WithRecording(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordedVM, recordedVDI := recordBootDisk(t, recording)
+	recording.Close()
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), testPassword) {
+		t.Fatal("cassette contains the password")
+	}
+
+	// the server is gone, everything has to come from the cassette
+	s.Close()
+
+	replaying, err := NewReplayClient(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replaying.Close()
+
+	vm, vdi := recordBootDisk(t, replaying)
+	if vm.ID != recordedVM.ID || vdi.ID != recordedVDI.ID || vdi.Size != 10<<30 {
+		t.Fatalf("replay differs from recording: %+v %+v", vm, vdi)
+	}
+
+	if remaining := replaying.ReplayRemaining(); remaining != 0 {
+		t.Fatalf("%d interactions were not replayed", remaining)
+	}
+
+	_, err = replaying.GetVirtualMachineByID(context.Background(), vm.ID)
+	if err == nil || !strings.Contains(err.Error(), "after the last recorded interaction") {
+		t.Fatalf("expected an error past the end of the cassette, got %v", err)
+	}
+}
+
+func TestCassetteReplayMismatch(t *testing.T) {
+	path := filepath.Join("testdata", "sign_in.jsonl")
+
+	c, err := NewReplayClient(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.SignInWithToken(context.Background(), "token")
+	if err == nil || !strings.Contains(err.Error(), "interaction 1 is session.signInWithPassword") {
+		t.Fatalf("expected a mismatch error, got %v", err)
+	}
+
+	c, err = NewReplayClient(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the recorded response was a rejection
+	err = c.SignIn(context.Background(), "admin", "any password")
+	if !errors.Is(err, UnauthorizedError) {
+		t.Fatalf("expected the recorded rejection, got %v", err)
+	}
+}
+
+func TestCassetteRecordSignIn(t *testing.T) {
+	const testToken = "s3cr3t-token"
+
+	s := xotest.NewServer()
+	defer s.Close()
+	s.AddUser("admin", testPassword)
+	s.AddToken(testToken)
+
+	path := filepath.Join(t.TempDir(), "sign_in.jsonl")
+
+	recording, err := NewClient(s.URL, WithRecording(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := recording.SignIn(ctx, "admin", testPassword); err != nil {
+		t.Fatal(err)
+	}
+	if err := recording.SignInWithToken(ctx, testToken); err != nil {
+		t.Fatal(err)
+	}
+	// tokens in any other response
+	recording.recorder.record("user.getAll", nil, json.RawMessage(`[{"id":"admin","preferences":{"authenticationToken":"`+testToken+`"}}]`), nil)
+	recording.Close()
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{testPassword, testToken} {
+		if strings.Contains(string(b), secret) {
+			t.Fatalf("cassette contains %q:\n%s", secret, b)
+		}
+	}
+
+	interactions, err := LoadCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(interactions) != 3 {
+		t.Fatalf("expected 3 interactions, got %d", len(interactions))
+	}
+	if got := string(interactions[0].Result); got != `{"email":"REDACTED","id":"admin","permission":"REDACTED"}` {
+		t.Fatalf("expected the sign-in response to be redacted, got %s", got)
+	}
+
+	// the redacted responses still replay
+	s.Close()
+
+	replaying, err := NewReplayClient(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replaying.Close()
+
+	if err := replaying.SignIn(ctx, "admin", testPassword); err != nil {
+		t.Fatal(err)
+	}
+	if err := replaying.SignInWithToken(ctx, testToken); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -2,8 +2,14 @@ package xo_client
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
 	"net/url"
 	"path"
+	"sync"
+	"time"
 
 	gws "github.com/gorilla/websocket"
 	"github.com/sourcegraph/jsonrpc2"
@@ -11,47 +17,153 @@ import (
 )
 
 type Client struct {
-	rpcConn *jsonrpc2.Conn
+	url    *url.URL
+	dialer *gws.Dialer
+
+	mu           sync.Mutex
+	rpcConn      *jsonrpc2.Conn
+	closed       bool
+	signInMethod string
+	signInParams map[string]interface{}
+
+	cache    *objectCache
+	tlsOpts  tlsOptions
+	proxyURL *url.URL
+	recorder *cassetteRecorder
+	replayer *cassetteReplayer
+
+	logRequests bool
+	limiter     limiter
+	retryPolicy RetryPolicy
 }
 
 type ObjectQuery map[string]string
 
-func NewClient(u *url.URL) (*Client, error) {
+type ClientOption func(c *Client) error
 
-	dialer := gws.Dialer{
-		ReadBufferSize:  4096,
-		WriteBufferSize: 4096,
+// WithObjectCache keeps an in-memory copy of all XO objects that is updated from server
+// notifications, so lookups don't need a round trip each time.
+func WithObjectCache() ClientOption {
+	return func(c *Client) error {
+		c.cache = newObjectCache()
+		return nil
+	}
+}
+
+func NewClient(u *url.URL, opts ...ClientOption) (*Client, error) {
+
+	dialer := &gws.Dialer{
+		ReadBufferSize:   4096,
+		WriteBufferSize:  4096,
+		HandshakeTimeout: 45 * time.Second,
 	}
 
 	u.Path = path.Join(u.Path, "api") + "/"
 
-	ws, _, err := dialer.Dial(u.String(), nil)
+	c := &Client{
+		url:         u,
+		dialer:      dialer,
+		retryPolicy: DefaultRetryPolicy,
+	}
+
+	for _, opt := range opts {
+		err := opt(c)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	dialer.TLSClientConfig = c.tlsOpts.config()
+
+	rpcConn, err := c.dial(context.Background())
 	if err != nil {
 		return nil, err
 	}
+	c.rpcConn = rpcConn
 
-	objStream := websocket.NewObjectStream(ws)
+	return c, nil
+}
+
+func (c *Client) dial(ctx context.Context) (*jsonrpc2.Conn, error) {
+	proxyURL, err := c.proxyForURL(c.url)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := *c.dialer
+	if proxyURL != nil {
+		pd := &proxyDialer{proxyURL: proxyURL, dialer: &net.Dialer{}}
+		dialer.NetDialContext = pd.DialContext
+	}
+
+	ws, resp, err := dialer.DialContext(ctx, c.url.String(), nil)
+	if err != nil {
+		if errors.Is(err, gws.ErrBadHandshake) && resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+			err = fmt.Errorf("%w: %v", TooManyConnectionsError, err)
+		}
 
-	rpcConn := jsonrpc2.NewConn(context.Background(), objStream, &noopHandler{})
+		// the proxy dialer returns a ProxyError for its own leg, anything else happened past
+		// the proxy, like the TLS handshake or websocket upgrade with XO
+		return nil, err
+	}
+
+	objStream := websocket.NewObjectStream(ws)
 
-	return &Client{
-		rpcConn: rpcConn,
-	}, nil
+	return jsonrpc2.NewConn(context.Background(), objStream, &notificationHandler{cache: c.cache}), nil
 }
 
 func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+
+	if c.recorder != nil {
+		c.recorder.close()
+	}
+
+	if c.rpcConn == nil {
+		return nil
+	}
 	return c.rpcConn.Close()
 }
 
+// ReplayRemaining returns how many interactions of the cassette a replay client hasn't
+// been asked for yet
+func (c *Client) ReplayRemaining() int {
+	if c.replayer == nil {
+		return 0
+	}
+	return c.replayer.remaining()
+}
+
 func (c *Client) SignIn(ctx context.Context, username, password string) error {
 	params := map[string]interface{}{
 		"email":    username,
 		"password": password,
 	}
-	var reply map[string]interface{}
-	return c.rpcConn.Call(ctx, "session.signInWithPassword", params, &reply)
+	return c.signIn(ctx, "session.signInWithPassword", params)
 }
 
-type noopHandler struct{}
+func (c *Client) SignInWithToken(ctx context.Context, token string) error {
+	params := map[string]interface{}{
+		"token": token,
+	}
+	return c.signIn(ctx, "session.signInWithToken", params)
+}
 
-func (*noopHandler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {}
+func (c *Client) signIn(ctx context.Context, method string, params map[string]interface{}) error {
+	var reply map[string]interface{}
+	err := c.call(ctx, method, params, &reply)
+	if err != nil {
+		return err
+	}
+
+	// keep the credentials around so the session can be re-established after a reconnect
+	c.mu.Lock()
+	c.signInMethod = method
+	c.signInParams = params
+	c.mu.Unlock()
+
+	return nil
+}
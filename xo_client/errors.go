@@ -2,6 +2,85 @@ package xo_client
 
 import (
 	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+var (
+	NotFoundError           = errors.New("Resource Not Found")
+	MultipleFoundError      = errors.New("Multiple Resources Found")
+	UnauthorizedError       = errors.New("Unauthorized")
+	InvalidParametersError  = errors.New("Invalid Parameters")
+	NoSuchObjectError       = errors.New("No Such Object")
+	OperationBlockedError   = errors.New("Operation Blocked")
+	TooManyConnectionsError = errors.New("Too Many Connections")
 )
 
-var NotFoundError = errors.New("Resource Not Found")
+// XO API error codes, see xo-common/api-errors
+const (
+	xoErrorNoSuchObject         = 1
+	xoErrorUnauthorized         = 2
+	xoErrorInvalidCredentials   = 3
+	xoErrorAuthenticationFailed = 8
+	xoErrorInvalidParameters    = 10
+	xoErrorOperationBlocked     = 19
+
+	jsonRPCErrorInvalidParams = -32602
+)
+
+// QueryError is returned when an object lookup doesn't match exactly one object
+type QueryError struct {
+	Type  string
+	Query Filter
+	Err   error
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("%s: %s with %s", e.Err, e.Type, e.Query)
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+// RPCError is returned when the XO API responds to a call with an error
+type RPCError struct {
+	Method string
+	Err    *jsonrpc2.Error
+}
+
+func (e *RPCError) Error() string {
+	msg := fmt.Sprintf("%s: %s (code %d)", e.Method, e.Err.Message, e.Err.Code)
+	if e.Err.Data != nil {
+		msg = fmt.Sprintf("%s: %s", msg, string(*e.Err.Data))
+	}
+	return msg
+}
+
+func (e *RPCError) Unwrap() error {
+	return e.Err
+}
+
+func (e *RPCError) Is(target error) bool {
+	switch target {
+	case NotFoundError, NoSuchObjectError:
+		return e.Err.Code == xoErrorNoSuchObject
+	case UnauthorizedError:
+		return e.Err.Code == xoErrorUnauthorized || e.Err.Code == xoErrorInvalidCredentials || e.Err.Code == xoErrorAuthenticationFailed
+	case InvalidParametersError:
+		return e.Err.Code == xoErrorInvalidParameters || e.Err.Code == jsonRPCErrorInvalidParams
+	case OperationBlockedError:
+		return e.Err.Code == xoErrorOperationBlocked
+	case TooManyConnectionsError:
+		return strings.Contains(strings.ToLower(e.Err.Message), "too many connections")
+	}
+
+	return false
+}
+
+// IsTransient reports whether the call that returned err may succeed when tried again
+func IsTransient(err error) bool {
+	return errors.Is(err, TooManyConnectionsError) || isConnectionError(err)
+}
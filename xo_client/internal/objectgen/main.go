@@ -0,0 +1,157 @@
+// objectgen generates the typed getters for the XO object types listed in a JSON spec
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+type byName struct {
+	// ScopeField is the object field the name lookup is restricted by, e.g. $pool
+	ScopeField string `json:"scopeField"`
+	// ScopeParam is the name of the getter parameter holding the scope value
+	ScopeParam string `json:"scopeParam"`
+}
+
+type objectType struct {
+	Name    string  `json:"name"`
+	APIType string  `json:"apiType"`
+	Plural  string  `json:"plural"`
+	ByName  *byName `json:"byName"`
+}
+
+func (o objectType) Var() string {
+	return lowerFirst(o.Name)
+}
+
+func (o objectType) PluralVar() string {
+	return lowerFirst(o.Plural)
+}
+
+// lowerFirst turns a Go type name into a variable name, initialisms like VDI are lowered as a whole
+func lowerFirst(s string) string {
+	upper := 0
+	for _, r := range s {
+		if !unicode.IsUpper(r) {
+			break
+		}
+		upper++
+	}
+
+	// VDIs -> vdis, VirtualMachine -> virtualMachine
+	if upper > 1 && upper < len(s) && unicode.IsLower([]rune(s)[upper]) && s[upper:] != "s" {
+		upper--
+	}
+
+	return strings.ToLower(s[:upper]) + s[upper:]
+}
+
+var tmpl = template.Must(template.New("objects").Parse(`// Code generated by objectgen from {{ .Spec }}. DO NOT EDIT.
+
+package xo_client
+
+import (
+	"context"
+	"encoding/json"
+)
+{{ range $t := .Types }}
+func decode{{ .Name }}(raw json.RawMessage) ({{ .Name }}, error) {
+	var {{ .Var }} {{ .Name }}
+	err := json.Unmarshal(raw, &{{ .Var }})
+	return {{ .Var }}, err
+}
+
+func (c *Client) List{{ .Plural }}(ctx context.Context, query Filter) ([]{{ .Name }}, error) {
+	objs, err := c.GetObjectsOfType(ctx, "{{ .APIType }}", query)
+	if err != nil {
+		return nil, err
+	}
+
+	{{ .PluralVar }} := make([]{{ .Name }}, 0, len(objs))
+	for _, raw := range objs {
+		{{ .Var }}, err := decode{{ .Name }}(raw)
+		if err != nil {
+			return nil, err
+		}
+		{{ .PluralVar }} = append({{ .PluralVar }}, {{ .Var }})
+	}
+
+	return {{ .PluralVar }}, nil
+}
+
+func (c *Client) Find{{ .Name }}(ctx context.Context, query Filter) (*{{ .Name }}, error) {
+	{{ .PluralVar }}, err := c.List{{ .Plural }}(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkSingleObject("{{ .APIType }}", query, len({{ .PluralVar }}))
+	if err != nil {
+		return nil, err
+	}
+
+	return &{{ .PluralVar }}[0], nil
+}
+
+func (c *Client) Get{{ .Name }}ByID(ctx context.Context, id string) (*{{ .Name }}, error) {
+	query := ObjectQuery{
+		"id": id,
+	}
+
+	return c.Find{{ .Name }}(ctx, query)
+}
+{{ with .ByName }}
+func (c *Client) Get{{ $t.Name }}ByName(ctx context.Context, {{ if .ScopeParam }}{{ .ScopeParam }}, {{ end }}name string) (*{{ $t.Name }}, error) {
+	query := ObjectQuery{
+		"name_label": name,
+		{{- if .ScopeField }}
+		"{{ .ScopeField }}": {{ .ScopeParam }},
+		{{- end }}
+	}
+
+	return c.Find{{ $t.Name }}(ctx, query)
+}
+{{ end }}{{ end }}`))
+
+func main() {
+	spec := flag.String("spec", "object_types.json", "JSON list of the XO object types")
+	out := flag.String("out", "objects_generated.go", "file to write the generated code to")
+	flag.Parse()
+
+	specBytes, err := ioutil.ReadFile(*spec)
+	if err != nil {
+		log.Fatalf("error reading spec: %v", err)
+	}
+
+	var types []objectType
+	err = json.Unmarshal(specBytes, &types)
+	if err != nil {
+		log.Fatalf("error parsing spec: %v", err)
+	}
+
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, map[string]interface{}{
+		"Spec":  *spec,
+		"Types": types,
+	})
+	if err != nil {
+		log.Fatalf("error executing template: %v", err)
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatalf("error formatting generated code: %v\n%s", err, buf.String())
+	}
+
+	err = ioutil.WriteFile(*out, src, 0644)
+	if err != nil {
+		log.Fatalf("error writing output: %v", err)
+	}
+}
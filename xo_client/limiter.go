@@ -0,0 +1,113 @@
+package xo_client
+
+import (
+	"context"
+	"log"
+	"math"
+	"sync"
+	"time"
+)
+
+// WithMaxConcurrentRequests limits how many calls can wait for a response from XO at once
+func WithMaxConcurrentRequests(n int) ClientOption {
+	return func(c *Client) error {
+		if n > 0 {
+			c.limiter.sem = make(chan struct{}, n)
+		}
+		return nil
+	}
+}
+
+// WithRequestsPerSecond limits how many calls are sent to XO per second, bursts of up to
+// one second worth of calls are allowed
+func WithRequestsPerSecond(rps float64) ClientOption {
+	return func(c *Client) error {
+		if rps > 0 {
+			c.limiter.bucket = newTokenBucket(rps, math.Max(1, rps))
+		}
+		return nil
+	}
+}
+
+type limiter struct {
+	sem    chan struct{}
+	bucket *tokenBucket
+}
+
+// acquire waits until a call may be sent, release has to be called once it is done
+func (l *limiter) acquire(ctx context.Context, method string) (release func(), err error) {
+	start := time.Now()
+	release = func() {}
+
+	if l.sem != nil {
+		select {
+		case l.sem <- struct{}{}:
+			release = func() { <-l.sem }
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	if l.bucket != nil {
+		err := l.bucket.wait(ctx)
+		if err != nil {
+			release()
+			return nil, err
+		}
+	}
+
+	if wait := time.Since(start); wait >= time.Millisecond {
+		log.Printf("[DEBUG] xo_client: waited %s for the request limiter before %s", wait, method)
+	}
+
+	return release, nil
+}
+
+// tokenBucket hands out tokens at rate per second, up to burst at once
+type tokenBucket struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate, burst float64) *tokenBucket {
+	return &tokenBucket{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// wait takes a token, waiting for one to become available if needed. Tokens are reserved
+// up front, so waiting callers are served in order.
+func (b *tokenBucket) wait(ctx context.Context) error {
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens--
+	delay := time.Duration(-b.tokens / b.rate * float64(time.Second))
+	b.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// hand the reservation back
+		b.mu.Lock()
+		b.tokens++
+		b.mu.Unlock()
+		return ctx.Err()
+	}
+}
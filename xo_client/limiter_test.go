@@ -0,0 +1,70 @@
+package xo_client
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rmb938/terraform-provider-xenorchestra/xo_client/xotest"
+)
+
+func TestTokenBucket(t *testing.T) {
+	b := newTokenBucket(20, 2)
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := b.wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the burst is free, the other two tokens take 50ms each
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Fatalf("expected the bucket to slow down calls, took %s", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := b.wait(ctx); err == nil {
+		t.Fatal("expected waiting on a cancelled context to fail")
+	}
+}
+
+func TestMaxConcurrentRequests(t *testing.T) {
+	s := xotest.NewServer()
+	defer s.Close()
+	s.AddUser("admin", testPassword)
+	s.AddPool("pool")
+
+	c, err := NewClient(s.URL, WithMaxConcurrentRequests(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.SignIn(context.Background(), "admin", testPassword); err != nil {
+		t.Fatal(err)
+	}
+
+	s.InjectFault(xotest.Fault{Method: "xo.getAllObjects", Delay: 50 * time.Millisecond})
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.ListPools(context.Background(), ObjectQuery{}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// six calls two at a time
+	if elapsed := time.Since(start); elapsed < 140*time.Millisecond {
+		t.Fatalf("expected calls to be limited to two at a time, took %s", elapsed)
+	}
+}
@@ -0,0 +1,37 @@
+package xo_client
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// WithRequestLogging logs every call made to XO to the plugin log. The method, redacted
+// params, duration, response size and error are logged at debug level, the response
+// itself, redacted too, at trace level.
+func WithRequestLogging() ClientOption {
+	return func(c *Client) error {
+		c.logRequests = true
+		return nil
+	}
+}
+
+func (c *Client) logCall(method string, params interface{}, attempt int, duration time.Duration, response json.RawMessage, err error) {
+	if !c.logRequests {
+		return
+	}
+
+	paramsJSON, _ := json.Marshal(redactParams(params))
+
+	errString := ""
+	if err != nil {
+		errString = err.Error()
+	}
+
+	log.Printf("[DEBUG] xo_client: rpc method=%s attempt=%d duration=%s response_bytes=%d error=%q params=%s",
+		method, attempt+1, duration, len(response), errString, paramsJSON)
+
+	if len(response) > 0 {
+		log.Printf("[TRACE] xo_client: rpc method=%s response=%s", method, redactResult(method, response))
+	}
+}
@@ -0,0 +1,54 @@
+package xo_client
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rmb938/terraform-provider-xenorchestra/xo_client/xotest"
+)
+
+func TestRequestLoggingRedaction(t *testing.T) {
+	s := xotest.NewServer()
+	defer s.Close()
+	s.AddUser("admin", testPassword)
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	c, err := NewClient(s.URL, WithRequestLogging())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.SignIn(context.Background(), "admin", testPassword); err != nil {
+		t.Fatal(err)
+	}
+
+	// failed calls are logged with their error
+	err = c.call(context.Background(), "vm.create", map[string]interface{}{
+		"template":    "missing",
+		"cloudConfig": "#cloud-config\npassword: secret",
+	}, nil)
+	if err == nil {
+		t.Fatal("expected vm.create to fail")
+	}
+
+	out := buf.String()
+	for _, secret := range []string{testPassword, "#cloud-config"} {
+		if strings.Contains(out, secret) {
+			t.Fatalf("log contains %q:\n%s", secret, out)
+		}
+	}
+
+	for _, want := range []string{"method=session.signInWithPassword", `"email":"admin"`, "method=vm.create", "no such object"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("log is missing %q:\n%s", want, out)
+		}
+	}
+}
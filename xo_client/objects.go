@@ -3,71 +3,108 @@ package xo_client
 import (
 	"context"
 	"encoding/json"
-	"fmt"
-	"reflect"
+	"strings"
 )
 
-type Objects map[string]interface{}
+//go:generate go run ./internal/objectgen -spec object_types.json -out objects_generated.go
 
-func (c *Client) GetObjectsOfType(ctx context.Context, apiType string, query ObjectQuery) (*Objects, error) {
-	filter := map[string]string{
+// Objects maps object IDs to the raw JSON of the objects so they can be decoded straight
+// into their typed struct
+type Objects map[string]json.RawMessage
+
+func (c *Client) GetObjectsOfType(ctx context.Context, apiType string, query Filter) (Objects, error) {
+	if c.cache != nil {
+		err := c.cache.load(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+
+		objs, ok := c.cache.find(apiType, query)
+
+		// objects created moments ago may not have been announced yet, so don't trust a
+		// miss on an id lookup
+		_, isIDQuery := queryID(query)
+		if ok && (!isIDQuery || len(objs) > 0) {
+			return objs, nil
+		}
+	}
+
+	filter := map[string]interface{}{
 		"type": apiType,
 	}
 
-	for k, v := range query {
-		filter[k] = v
+	if pattern, ok := query.pattern(); ok {
+		patternMap, isMap := pattern.(map[string]interface{})
+		_, hasType := patternMap["type"]
+		if isMap && !hasType && !isOperatorPattern(patternMap) {
+			for k, v := range patternMap {
+				filter[k] = v
+			}
+		} else {
+			filter = map[string]interface{}{
+				"__and": []interface{}{filter, pattern},
+			}
+		}
 	}
 
 	params := map[string]interface{}{
 		"filter": filter,
 	}
 
-	objs := &Objects{}
-	err := c.rpcConn.Call(ctx, "xo.getAllObjects", params, objs)
+	objs := Objects{}
+	err := c.call(ctx, "xo.getAllObjects", params, &objs)
 	if err != nil {
 		return nil, err
 	}
 
-	return objs, nil
-}
-
-func (o *Objects) ConvertToSlice(obj interface{}) (interface{}, error) {
-	t := reflect.TypeOf(obj)
-	objs := reflect.MakeSlice(reflect.SliceOf(t), 0, 0)
+	if !query.local() {
+		return objs, nil
+	}
 
-	for _, obj := range *o {
-		objBytes, err := json.Marshal(obj)
+	// apply the parts of the query XO could not evaluate
+	for id, raw := range objs {
+		var obj interface{}
+		err := json.Unmarshal(raw, &obj)
 		if err != nil {
 			return nil, err
 		}
 
-		item := reflect.New(t)
-		err = json.Unmarshal(objBytes, item.Interface())
-		if err != nil {
-			return nil, err
+		if !query.Match(obj) {
+			delete(objs, id)
 		}
-
-		objs = reflect.Append(objs, item.Elem())
 	}
 
-	return objs.Interface(), nil
+	return objs, nil
 }
 
-func (o *Objects) ConvertToSingle(obj interface{}) (interface{}, error) {
-	interf, err := o.ConvertToSlice(obj)
-	if err != nil {
-		return nil, err
+func checkSingleObject(apiType string, query Filter, count int) error {
+	if count == 0 {
+		return &QueryError{Type: apiType, Query: query, Err: NotFoundError}
 	}
 
-	interfSlice := reflect.ValueOf(interf)
-
-	if interfSlice.Len() == 0 {
-		return nil, NotFoundError
+	if count > 1 {
+		return &QueryError{Type: apiType, Query: query, Err: MultipleFoundError}
 	}
 
-	if interfSlice.Len() > 1 {
-		return nil, fmt.Errorf("found multiple")
+	return nil
+}
+
+// queryID returns the id an ObjectQuery looks up, ok is false for any other query
+func queryID(query Filter) (id string, ok bool) {
+	q, ok := query.(ObjectQuery)
+	if !ok {
+		return "", false
 	}
 
-	return interfSlice.Index(0).Interface(), nil
+	id, ok = q["id"]
+	return id, ok
+}
+
+func isOperatorPattern(pattern map[string]interface{}) bool {
+	for k := range pattern {
+		if strings.HasPrefix(k, "__") {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,429 @@
+// Code generated by objectgen from object_types.json. DO NOT EDIT.
+
+package xo_client
+
+import (
+	"context"
+	"encoding/json"
+)
+
+func decodePool(raw json.RawMessage) (Pool, error) {
+	var pool Pool
+	err := json.Unmarshal(raw, &pool)
+	return pool, err
+}
+
+func (c *Client) ListPools(ctx context.Context, query Filter) ([]Pool, error) {
+	objs, err := c.GetObjectsOfType(ctx, "pool", query)
+	if err != nil {
+		return nil, err
+	}
+
+	pools := make([]Pool, 0, len(objs))
+	for _, raw := range objs {
+		pool, err := decodePool(raw)
+		if err != nil {
+			return nil, err
+		}
+		pools = append(pools, pool)
+	}
+
+	return pools, nil
+}
+
+func (c *Client) FindPool(ctx context.Context, query Filter) (*Pool, error) {
+	pools, err := c.ListPools(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkSingleObject("pool", query, len(pools))
+	if err != nil {
+		return nil, err
+	}
+
+	return &pools[0], nil
+}
+
+func (c *Client) GetPoolByID(ctx context.Context, id string) (*Pool, error) {
+	query := ObjectQuery{
+		"id": id,
+	}
+
+	return c.FindPool(ctx, query)
+}
+
+func (c *Client) GetPoolByName(ctx context.Context, name string) (*Pool, error) {
+	query := ObjectQuery{
+		"name_label": name,
+	}
+
+	return c.FindPool(ctx, query)
+}
+
+func decodeNetwork(raw json.RawMessage) (Network, error) {
+	var network Network
+	err := json.Unmarshal(raw, &network)
+	return network, err
+}
+
+func (c *Client) ListNetworks(ctx context.Context, query Filter) ([]Network, error) {
+	objs, err := c.GetObjectsOfType(ctx, "network", query)
+	if err != nil {
+		return nil, err
+	}
+
+	networks := make([]Network, 0, len(objs))
+	for _, raw := range objs {
+		network, err := decodeNetwork(raw)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
+
+func (c *Client) FindNetwork(ctx context.Context, query Filter) (*Network, error) {
+	networks, err := c.ListNetworks(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkSingleObject("network", query, len(networks))
+	if err != nil {
+		return nil, err
+	}
+
+	return &networks[0], nil
+}
+
+func (c *Client) GetNetworkByID(ctx context.Context, id string) (*Network, error) {
+	query := ObjectQuery{
+		"id": id,
+	}
+
+	return c.FindNetwork(ctx, query)
+}
+
+func (c *Client) GetNetworkByName(ctx context.Context, poolID, name string) (*Network, error) {
+	query := ObjectQuery{
+		"name_label": name,
+		"$pool":      poolID,
+	}
+
+	return c.FindNetwork(ctx, query)
+}
+
+func decodeTemplate(raw json.RawMessage) (Template, error) {
+	var template Template
+	err := json.Unmarshal(raw, &template)
+	return template, err
+}
+
+func (c *Client) ListTemplates(ctx context.Context, query Filter) ([]Template, error) {
+	objs, err := c.GetObjectsOfType(ctx, "VM-template", query)
+	if err != nil {
+		return nil, err
+	}
+
+	templates := make([]Template, 0, len(objs))
+	for _, raw := range objs {
+		template, err := decodeTemplate(raw)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, template)
+	}
+
+	return templates, nil
+}
+
+func (c *Client) FindTemplate(ctx context.Context, query Filter) (*Template, error) {
+	templates, err := c.ListTemplates(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkSingleObject("VM-template", query, len(templates))
+	if err != nil {
+		return nil, err
+	}
+
+	return &templates[0], nil
+}
+
+func (c *Client) GetTemplateByID(ctx context.Context, id string) (*Template, error) {
+	query := ObjectQuery{
+		"id": id,
+	}
+
+	return c.FindTemplate(ctx, query)
+}
+
+func (c *Client) GetTemplateByName(ctx context.Context, poolID, name string) (*Template, error) {
+	query := ObjectQuery{
+		"name_label": name,
+		"$pool":      poolID,
+	}
+
+	return c.FindTemplate(ctx, query)
+}
+
+func decodeStorageRepository(raw json.RawMessage) (StorageRepository, error) {
+	var storageRepository StorageRepository
+	err := json.Unmarshal(raw, &storageRepository)
+	return storageRepository, err
+}
+
+func (c *Client) ListStorageRepositories(ctx context.Context, query Filter) ([]StorageRepository, error) {
+	objs, err := c.GetObjectsOfType(ctx, "SR", query)
+	if err != nil {
+		return nil, err
+	}
+
+	storageRepositories := make([]StorageRepository, 0, len(objs))
+	for _, raw := range objs {
+		storageRepository, err := decodeStorageRepository(raw)
+		if err != nil {
+			return nil, err
+		}
+		storageRepositories = append(storageRepositories, storageRepository)
+	}
+
+	return storageRepositories, nil
+}
+
+func (c *Client) FindStorageRepository(ctx context.Context, query Filter) (*StorageRepository, error) {
+	storageRepositories, err := c.ListStorageRepositories(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkSingleObject("SR", query, len(storageRepositories))
+	if err != nil {
+		return nil, err
+	}
+
+	return &storageRepositories[0], nil
+}
+
+func (c *Client) GetStorageRepositoryByID(ctx context.Context, id string) (*StorageRepository, error) {
+	query := ObjectQuery{
+		"id": id,
+	}
+
+	return c.FindStorageRepository(ctx, query)
+}
+
+func (c *Client) GetStorageRepositoryByName(ctx context.Context, poolID, name string) (*StorageRepository, error) {
+	query := ObjectQuery{
+		"name_label": name,
+		"$pool":      poolID,
+	}
+
+	return c.FindStorageRepository(ctx, query)
+}
+
+func decodeVDI(raw json.RawMessage) (VDI, error) {
+	var vdi VDI
+	err := json.Unmarshal(raw, &vdi)
+	return vdi, err
+}
+
+func (c *Client) ListVDIs(ctx context.Context, query Filter) ([]VDI, error) {
+	objs, err := c.GetObjectsOfType(ctx, "VDI", query)
+	if err != nil {
+		return nil, err
+	}
+
+	vdis := make([]VDI, 0, len(objs))
+	for _, raw := range objs {
+		vdi, err := decodeVDI(raw)
+		if err != nil {
+			return nil, err
+		}
+		vdis = append(vdis, vdi)
+	}
+
+	return vdis, nil
+}
+
+func (c *Client) FindVDI(ctx context.Context, query Filter) (*VDI, error) {
+	vdis, err := c.ListVDIs(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkSingleObject("VDI", query, len(vdis))
+	if err != nil {
+		return nil, err
+	}
+
+	return &vdis[0], nil
+}
+
+func (c *Client) GetVDIByID(ctx context.Context, id string) (*VDI, error) {
+	query := ObjectQuery{
+		"id": id,
+	}
+
+	return c.FindVDI(ctx, query)
+}
+
+func (c *Client) GetVDIByName(ctx context.Context, storageRepositoryID, name string) (*VDI, error) {
+	query := ObjectQuery{
+		"name_label": name,
+		"$SR":        storageRepositoryID,
+	}
+
+	return c.FindVDI(ctx, query)
+}
+
+func decodeVBD(raw json.RawMessage) (VBD, error) {
+	var vbd VBD
+	err := json.Unmarshal(raw, &vbd)
+	return vbd, err
+}
+
+func (c *Client) ListVBDs(ctx context.Context, query Filter) ([]VBD, error) {
+	objs, err := c.GetObjectsOfType(ctx, "VBD", query)
+	if err != nil {
+		return nil, err
+	}
+
+	vbds := make([]VBD, 0, len(objs))
+	for _, raw := range objs {
+		vbd, err := decodeVBD(raw)
+		if err != nil {
+			return nil, err
+		}
+		vbds = append(vbds, vbd)
+	}
+
+	return vbds, nil
+}
+
+func (c *Client) FindVBD(ctx context.Context, query Filter) (*VBD, error) {
+	vbds, err := c.ListVBDs(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkSingleObject("VBD", query, len(vbds))
+	if err != nil {
+		return nil, err
+	}
+
+	return &vbds[0], nil
+}
+
+func (c *Client) GetVBDByID(ctx context.Context, id string) (*VBD, error) {
+	query := ObjectQuery{
+		"id": id,
+	}
+
+	return c.FindVBD(ctx, query)
+}
+
+func decodeVIF(raw json.RawMessage) (VIF, error) {
+	var vif VIF
+	err := json.Unmarshal(raw, &vif)
+	return vif, err
+}
+
+func (c *Client) ListVIFs(ctx context.Context, query Filter) ([]VIF, error) {
+	objs, err := c.GetObjectsOfType(ctx, "VIF", query)
+	if err != nil {
+		return nil, err
+	}
+
+	vifs := make([]VIF, 0, len(objs))
+	for _, raw := range objs {
+		vif, err := decodeVIF(raw)
+		if err != nil {
+			return nil, err
+		}
+		vifs = append(vifs, vif)
+	}
+
+	return vifs, nil
+}
+
+func (c *Client) FindVIF(ctx context.Context, query Filter) (*VIF, error) {
+	vifs, err := c.ListVIFs(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkSingleObject("VIF", query, len(vifs))
+	if err != nil {
+		return nil, err
+	}
+
+	return &vifs[0], nil
+}
+
+func (c *Client) GetVIFByID(ctx context.Context, id string) (*VIF, error) {
+	query := ObjectQuery{
+		"id": id,
+	}
+
+	return c.FindVIF(ctx, query)
+}
+
+func decodeVirtualMachine(raw json.RawMessage) (VirtualMachine, error) {
+	var virtualMachine VirtualMachine
+	err := json.Unmarshal(raw, &virtualMachine)
+	return virtualMachine, err
+}
+
+func (c *Client) ListVirtualMachines(ctx context.Context, query Filter) ([]VirtualMachine, error) {
+	objs, err := c.GetObjectsOfType(ctx, "VM", query)
+	if err != nil {
+		return nil, err
+	}
+
+	virtualMachines := make([]VirtualMachine, 0, len(objs))
+	for _, raw := range objs {
+		virtualMachine, err := decodeVirtualMachine(raw)
+		if err != nil {
+			return nil, err
+		}
+		virtualMachines = append(virtualMachines, virtualMachine)
+	}
+
+	return virtualMachines, nil
+}
+
+func (c *Client) FindVirtualMachine(ctx context.Context, query Filter) (*VirtualMachine, error) {
+	virtualMachines, err := c.ListVirtualMachines(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkSingleObject("VM", query, len(virtualMachines))
+	if err != nil {
+		return nil, err
+	}
+
+	return &virtualMachines[0], nil
+}
+
+func (c *Client) GetVirtualMachineByID(ctx context.Context, id string) (*VirtualMachine, error) {
+	query := ObjectQuery{
+		"id": id,
+	}
+
+	return c.FindVirtualMachine(ctx, query)
+}
+
+func (c *Client) GetVirtualMachineByName(ctx context.Context, poolID, name string) (*VirtualMachine, error) {
+	query := ObjectQuery{
+		"name_label": name,
+		"$pool":      poolID,
+	}
+
+	return c.FindVirtualMachine(ctx, query)
+}
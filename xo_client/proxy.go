@@ -0,0 +1,191 @@
+package xo_client
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// ProxyError is returned when the connection to XO fails because of the proxy, Hop says
+// which leg of the connection failed
+type ProxyError struct {
+	Proxy *url.URL
+	Hop   string
+	Err   error
+}
+
+func (e *ProxyError) Error() string {
+	return fmt.Sprintf("proxy %s: %s: %v", e.Proxy.Redacted(), e.Hop, e.Err)
+}
+
+func (e *ProxyError) Unwrap() error {
+	return e.Err
+}
+
+// WithProxy sends the websocket connection through an http, https or socks5 proxy instead
+// of the one configured in the environment
+func WithProxy(proxyURL *url.URL) ClientOption {
+	return func(c *Client) error {
+		switch proxyURL.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return fmt.Errorf("unsupported proxy scheme %q", proxyURL.Scheme)
+		}
+
+		c.proxyURL = proxyURL
+		return nil
+	}
+}
+
+// proxyForURL returns the proxy to use for u, falling back to HTTPS_PROXY, HTTP_PROXY and
+// NO_PROXY when none was configured
+func (c *Client) proxyForURL(u *url.URL) (*url.URL, error) {
+	if c.proxyURL != nil {
+		return c.proxyURL, nil
+	}
+
+	scheme := "http"
+	if u.Scheme == "wss" {
+		scheme = "https"
+	}
+
+	return http.ProxyFromEnvironment(&http.Request{URL: &url.URL{Scheme: scheme, Host: u.Host}})
+}
+
+type proxyDialer struct {
+	proxyURL *url.URL
+	dialer   *net.Dialer
+}
+
+func (pd *proxyDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	switch pd.proxyURL.Scheme {
+	case "socks5", "socks5h":
+		return pd.dialSOCKS5(ctx, network, addr)
+	default:
+		return pd.dialConnect(ctx, network, addr)
+	}
+}
+
+func (pd *proxyDialer) proxyAddr() string {
+	if len(pd.proxyURL.Port()) > 0 {
+		return pd.proxyURL.Host
+	}
+
+	port := "80"
+	switch pd.proxyURL.Scheme {
+	case "https":
+		port = "443"
+	case "socks5", "socks5h":
+		port = "1080"
+	}
+
+	return net.JoinHostPort(pd.proxyURL.Hostname(), port)
+}
+
+func (pd *proxyDialer) dialConnect(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := pd.dialer.DialContext(ctx, network, pd.proxyAddr())
+	if err != nil {
+		return nil, &ProxyError{Proxy: pd.proxyURL, Hop: "connecting to proxy", Err: err}
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	if pd.proxyURL.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: pd.proxyURL.Hostname()})
+		err = tlsConn.Handshake()
+		if err != nil {
+			conn.Close()
+			return nil, &ProxyError{Proxy: pd.proxyURL, Hop: "TLS handshake with proxy", Err: err}
+		}
+		conn = tlsConn
+	}
+
+	connectHeader := make(http.Header)
+	if user := pd.proxyURL.User; user != nil {
+		password, _ := user.Password()
+		credential := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		connectHeader.Set("Proxy-Authorization", "Basic "+credential)
+	}
+
+	connectReq := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: connectHeader,
+	}
+
+	err = connectReq.Write(conn)
+	if err != nil {
+		conn.Close()
+		return nil, &ProxyError{Proxy: pd.proxyURL, Hop: fmt.Sprintf("CONNECT to %s", addr), Err: err}
+	}
+
+	// the target doesn't speak until spoken to so nothing past the response is buffered
+	resp, err := http.ReadResponse(bufio.NewReader(conn), connectReq)
+	if err != nil {
+		conn.Close()
+		return nil, &ProxyError{Proxy: pd.proxyURL, Hop: fmt.Sprintf("CONNECT to %s", addr), Err: err}
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, &ProxyError{Proxy: pd.proxyURL, Hop: fmt.Sprintf("CONNECT to %s", addr), Err: fmt.Errorf("proxy responded with %s", resp.Status)}
+	}
+
+	return conn, nil
+}
+
+func (pd *proxyDialer) dialSOCKS5(ctx context.Context, network, addr string) (net.Conn, error) {
+	var auth *proxy.Auth
+	if user := pd.proxyURL.User; user != nil {
+		password, _ := user.Password()
+		auth = &proxy.Auth{
+			User:     user.Username(),
+			Password: password,
+		}
+	}
+
+	forward := &recordingDialer{dialer: pd.dialer}
+	socksDialer, err := proxy.SOCKS5("tcp", pd.proxyAddr(), auth, forward)
+	if err != nil {
+		return nil, &ProxyError{Proxy: pd.proxyURL, Hop: "configuring SOCKS5 proxy", Err: err}
+	}
+
+	conn, err := socksDialer.(proxy.ContextDialer).DialContext(ctx, network, addr)
+	if err != nil {
+		if forward.err != nil {
+			return nil, &ProxyError{Proxy: pd.proxyURL, Hop: "connecting to proxy", Err: forward.err}
+		}
+		return nil, &ProxyError{Proxy: pd.proxyURL, Hop: fmt.Sprintf("SOCKS5 connect to %s", addr), Err: err}
+	}
+
+	return conn, nil
+}
+
+// recordingDialer remembers whether reaching the proxy itself failed
+type recordingDialer struct {
+	dialer *net.Dialer
+	err    error
+}
+
+func (rd *recordingDialer) Dial(network, addr string) (net.Conn, error) {
+	return rd.DialContext(context.Background(), network, addr)
+}
+
+func (rd *recordingDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := rd.dialer.DialContext(ctx, network, addr)
+	rd.err = err
+	return conn, err
+}
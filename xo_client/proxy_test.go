@@ -0,0 +1,185 @@
+package xo_client
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rmb938/terraform-provider-xenorchestra/xo_client/xotest"
+)
+
+// connectProxy is a CONNECT proxy that asks for the Proxy-Authorization header when
+// authorization is set
+type connectProxy struct {
+	listener      net.Listener
+	authorization string
+
+	mu      sync.Mutex
+	tunnels []string
+}
+
+func newConnectProxy(t *testing.T, authorization string) *connectProxy {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	p := &connectProxy{listener: listener, authorization: authorization}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go p.serve(conn)
+		}
+	}()
+
+	return p
+}
+
+func (p *connectProxy) url(userinfo *url.Userinfo) *url.URL {
+	return &url.URL{Scheme: "http", User: userinfo, Host: p.listener.Addr().String()}
+}
+
+func (p *connectProxy) serve(conn net.Conn) {
+	defer conn.Close()
+
+	req, err := http.ReadRequest(bufio.NewReader(conn))
+	if err != nil || req.Method != http.MethodConnect {
+		return
+	}
+
+	if len(p.authorization) > 0 && req.Header.Get("Proxy-Authorization") != p.authorization {
+		io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\nContent-Length: 0\r\n\r\n")
+		return
+	}
+
+	target, err := net.Dial("tcp", req.Host)
+	if err != nil {
+		io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\nContent-Length: 0\r\n\r\n")
+		return
+	}
+	defer target.Close()
+
+	p.mu.Lock()
+	p.tunnels = append(p.tunnels, req.Host)
+	p.mu.Unlock()
+
+	io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+	go io.Copy(target, conn)
+	io.Copy(conn, target)
+}
+
+func TestProxy(t *testing.T) {
+	s := xotest.NewServer()
+	defer s.Close()
+	s.AddUser("admin", testPassword)
+
+	p := newConnectProxy(t, "Basic YWxpY2U6c2VjcmV0")
+
+	c, err := NewClient(&url.URL{Scheme: s.URL.Scheme, Host: s.URL.Host}, WithProxy(p.url(url.UserPassword("alice", "secret"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.SignIn(context.Background(), "admin", testPassword); err != nil {
+		t.Fatal(err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.tunnels) != 1 || p.tunnels[0] != s.URL.Host {
+		t.Fatalf("expected a tunnel to %s, got %v", s.URL.Host, p.tunnels)
+	}
+}
+
+func TestProxyErrors(t *testing.T) {
+	s := xotest.NewServer()
+	defer s.Close()
+
+	p := newConnectProxy(t, "Basic YWxpY2U6c2VjcmV0")
+
+	// a port nothing listens on anymore
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreachable := &url.URL{Scheme: "http", Host: listener.Addr().String()}
+	listener.Close()
+
+	tests := []struct {
+		name   string
+		scheme string
+		proxy  *url.URL
+		hop    string
+		err    string
+	}{
+		{
+			name:   "unreachable proxy",
+			scheme: "ws",
+			proxy:  unreachable,
+			hop:    "connecting to proxy",
+			err:    "connection refused",
+		},
+		{
+			name:   "proxy authentication required",
+			scheme: "ws",
+			proxy:  p.url(nil),
+			hop:    "CONNECT to " + s.URL.Host,
+			err:    "407 Proxy Authentication Required",
+		},
+		{
+			name:   "wrong proxy credentials",
+			scheme: "ws",
+			proxy:  p.url(url.UserPassword("alice", "wrong")),
+			hop:    "CONNECT to " + s.URL.Host,
+			err:    "407 Proxy Authentication Required",
+		},
+		{
+			// the fake server doesn't speak TLS, XO failed and not the proxy
+			name:   "TLS handshake with XO",
+			scheme: "wss",
+			proxy:  p.url(url.UserPassword("alice", "secret")),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := NewClient(&url.URL{Scheme: test.scheme, Host: s.URL.Host}, WithProxy(test.proxy))
+			if err == nil {
+				c.Close()
+				t.Fatal("expected an error")
+			}
+
+			var proxyErr *ProxyError
+			if len(test.hop) == 0 {
+				if errors.As(err, &proxyErr) {
+					t.Fatalf("expected an error that doesn't blame the proxy, got %v", err)
+				}
+				return
+			}
+
+			if !errors.As(err, &proxyErr) {
+				t.Fatalf("expected a ProxyError, got %v", err)
+			}
+			if proxyErr.Hop != test.hop {
+				t.Fatalf("expected hop %q, got %q", test.hop, proxyErr.Hop)
+			}
+			if !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("expected an error containing %q, got %v", test.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,405 @@
+package xo_client
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Filter selects XO objects. The parts of a filter that XO understands are sent along with
+// xo.getAllObjects, the rest is applied to the objects once they are fetched.
+//
+// Fields are dotted paths into the object, e.g. other.xo:resource_set
+type Filter interface {
+	// Match reports whether the object matches the filter
+	Match(obj interface{}) bool
+	// pattern returns the xo-server value-matcher pattern for the filter. When XO can't
+	// evaluate the filter ok is false.
+	pattern() (pattern interface{}, ok bool)
+	// local reports whether the filter has to be applied after fetching
+	local() bool
+	String() string
+}
+
+func (q ObjectQuery) Match(obj interface{}) bool {
+	for k, v := range q {
+		value, _ := lookupField(obj, []string{k})
+		if s, ok := value.(string); !ok || s != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (q ObjectQuery) pattern() (interface{}, bool) {
+	pattern := map[string]interface{}{}
+	for k, v := range q {
+		pattern[k] = v
+	}
+	return pattern, true
+}
+
+func (q ObjectQuery) local() bool {
+	return false
+}
+
+func (q ObjectQuery) String() string {
+	var conditions []string
+	for k, v := range q {
+		conditions = append(conditions, fmt.Sprintf("%s=%q", k, v))
+	}
+	sort.Strings(conditions)
+
+	return strings.Join(conditions, ", ")
+}
+
+type eqFilter struct {
+	field []string
+	value interface{}
+}
+
+// Eq matches objects where field is equal to value, value can be any JSON type
+func Eq(field string, value interface{}) Filter {
+	return &eqFilter{field: splitField(field), value: normalizeValue(value)}
+}
+
+func (f *eqFilter) Match(obj interface{}) bool {
+	value, ok := lookupField(obj, f.field)
+	return ok && reflect.DeepEqual(value, f.value)
+}
+
+func (f *eqFilter) pattern() (interface{}, bool) {
+	switch f.value.(type) {
+	case []interface{}, map[string]interface{}:
+		// XO treats these as partial matches, not equality
+		return nil, false
+	}
+	return nestPattern(f.field, f.value), true
+}
+
+func (f *eqFilter) local() bool {
+	_, ok := f.pattern()
+	return !ok
+}
+
+func (f *eqFilter) String() string {
+	return fmt.Sprintf("%s == %s", strings.Join(f.field, "."), formatValue(f.value))
+}
+
+// In matches objects where field is equal to any of the values
+func In(field string, values ...interface{}) Filter {
+	var filters []Filter
+	for _, value := range values {
+		filters = append(filters, Eq(field, value))
+	}
+	return Or(filters...)
+}
+
+type containsFilter struct {
+	field  []string
+	values []interface{}
+}
+
+// Contains matches objects where field is an array holding all of the values
+func Contains(field string, values ...interface{}) Filter {
+	f := &containsFilter{field: splitField(field)}
+	for _, value := range values {
+		f.values = append(f.values, normalizeValue(value))
+	}
+	return f
+}
+
+func (f *containsFilter) Match(obj interface{}) bool {
+	value, _ := lookupField(obj, f.field)
+	items, ok := value.([]interface{})
+	if !ok {
+		return false
+	}
+
+	for _, want := range f.values {
+		found := false
+		for _, item := range items {
+			if reflect.DeepEqual(item, want) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (f *containsFilter) pattern() (interface{}, bool) {
+	for _, value := range f.values {
+		switch value.(type) {
+		case []interface{}, map[string]interface{}:
+			return nil, false
+		}
+	}
+	return nestPattern(f.field, f.values), true
+}
+
+func (f *containsFilter) local() bool {
+	_, ok := f.pattern()
+	return !ok
+}
+
+func (f *containsFilter) String() string {
+	var values []string
+	for _, value := range f.values {
+		values = append(values, formatValue(value))
+	}
+	return fmt.Sprintf("%s contains [%s]", strings.Join(f.field, "."), strings.Join(values, ", "))
+}
+
+type prefixFilter struct {
+	field  []string
+	prefix string
+}
+
+// HasPrefix matches objects where field is a string starting with prefix
+func HasPrefix(field, prefix string) Filter {
+	return &prefixFilter{field: splitField(field), prefix: prefix}
+}
+
+func (f *prefixFilter) Match(obj interface{}) bool {
+	value, _ := lookupField(obj, f.field)
+	s, ok := value.(string)
+	return ok && strings.HasPrefix(s, f.prefix)
+}
+
+func (f *prefixFilter) pattern() (interface{}, bool) {
+	return nil, false
+}
+
+func (f *prefixFilter) local() bool {
+	return true
+}
+
+func (f *prefixFilter) String() string {
+	return fmt.Sprintf("%s has prefix %q", strings.Join(f.field, "."), f.prefix)
+}
+
+type regexpFilter struct {
+	field []string
+	re    *regexp.Regexp
+}
+
+// MatchesRegexp matches objects where field is a string matching re
+func MatchesRegexp(field string, re *regexp.Regexp) Filter {
+	return &regexpFilter{field: splitField(field), re: re}
+}
+
+func (f *regexpFilter) Match(obj interface{}) bool {
+	value, _ := lookupField(obj, f.field)
+	s, ok := value.(string)
+	return ok && f.re.MatchString(s)
+}
+
+func (f *regexpFilter) pattern() (interface{}, bool) {
+	return nil, false
+}
+
+func (f *regexpFilter) local() bool {
+	return true
+}
+
+func (f *regexpFilter) String() string {
+	return fmt.Sprintf("%s =~ /%s/", strings.Join(f.field, "."), f.re)
+}
+
+type andFilter []Filter
+
+// And matches objects matching all of the filters
+func And(filters ...Filter) Filter {
+	return andFilter(filters)
+}
+
+func (f andFilter) Match(obj interface{}) bool {
+	for _, filter := range f {
+		if !filter.Match(obj) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f andFilter) pattern() (interface{}, bool) {
+	// filters XO can't evaluate are left out, the result is a superset that gets
+	// narrowed down after fetching
+	var patterns []interface{}
+	for _, filter := range f {
+		if pattern, ok := filter.pattern(); ok {
+			patterns = append(patterns, pattern)
+		}
+	}
+
+	switch len(patterns) {
+	case 0:
+		return nil, false
+	case 1:
+		return patterns[0], true
+	}
+	return map[string]interface{}{"__and": patterns}, true
+}
+
+func (f andFilter) local() bool {
+	return anyLocal(f)
+}
+
+func (f andFilter) String() string {
+	return joinFilters(f, " AND ")
+}
+
+type orFilter []Filter
+
+// Or matches objects matching any of the filters
+func Or(filters ...Filter) Filter {
+	return orFilter(filters)
+}
+
+func (f orFilter) Match(obj interface{}) bool {
+	for _, filter := range f {
+		if filter.Match(obj) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f orFilter) pattern() (interface{}, bool) {
+	var patterns []interface{}
+	for _, filter := range f {
+		pattern, ok := filter.pattern()
+		if !ok {
+			return nil, false
+		}
+		patterns = append(patterns, pattern)
+	}
+
+	if len(patterns) == 0 {
+		return nil, false
+	}
+	return map[string]interface{}{"__or": patterns}, true
+}
+
+func (f orFilter) local() bool {
+	_, ok := f.pattern()
+	return !ok || anyLocal(f)
+}
+
+func (f orFilter) String() string {
+	return joinFilters(f, " OR ")
+}
+
+type notFilter struct {
+	filter Filter
+}
+
+// Not matches objects not matching the filter
+func Not(filter Filter) Filter {
+	return &notFilter{filter: filter}
+}
+
+func (f *notFilter) Match(obj interface{}) bool {
+	return !f.filter.Match(obj)
+}
+
+func (f *notFilter) pattern() (interface{}, bool) {
+	// the pattern of a filter with local parts matches a superset, negating it would
+	// leave out objects that match
+	if f.filter.local() {
+		return nil, false
+	}
+
+	pattern, ok := f.filter.pattern()
+	if !ok {
+		return nil, false
+	}
+	return map[string]interface{}{"__not": pattern}, true
+}
+
+func (f *notFilter) local() bool {
+	_, ok := f.pattern()
+	return !ok
+}
+
+func (f *notFilter) String() string {
+	return fmt.Sprintf("NOT (%s)", f.filter)
+}
+
+func anyLocal(filters []Filter) bool {
+	for _, filter := range filters {
+		if filter.local() {
+			return true
+		}
+	}
+	return false
+}
+
+func joinFilters(filters []Filter, sep string) string {
+	var parts []string
+	for _, filter := range filters {
+		parts = append(parts, fmt.Sprintf("(%s)", filter))
+	}
+	return strings.Join(parts, sep)
+}
+
+func splitField(field string) []string {
+	return strings.Split(field, ".")
+}
+
+func lookupField(obj interface{}, field []string) (interface{}, bool) {
+	value := obj
+	for _, key := range field {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+
+		value, ok = m[key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func nestPattern(field []string, value interface{}) interface{} {
+	pattern := value
+	for i := len(field) - 1; i >= 0; i-- {
+		pattern = map[string]interface{}{field[i]: pattern}
+	}
+	return pattern
+}
+
+// normalizeValue converts value to the types encoding/json decodes objects into so it can
+// be compared to fetched objects
+func normalizeValue(value interface{}) interface{} {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+
+	var normalized interface{}
+	err = json.Unmarshal(b, &normalized)
+	if err != nil {
+		return value
+	}
+	return normalized
+}
+
+func formatValue(value interface{}) string {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(b)
+}
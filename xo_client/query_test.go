@@ -0,0 +1,181 @@
+package xo_client
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"testing"
+
+	"github.com/rmb938/terraform-provider-xenorchestra/xo_client/xotest"
+)
+
+var queryTests = []struct {
+	name    string
+	filter  Filter
+	pattern string
+	local   bool
+}{
+	{
+		name:    "remote",
+		filter:  Eq("name_label", "a"),
+		pattern: `{"name_label":"a"}`,
+	},
+	{
+		name:   "local",
+		filter: HasPrefix("name_label", "x"),
+		local:  true,
+	},
+	{
+		name:    "and remote",
+		filter:  And(Eq("name_label", "a"), Eq("name_description", "first")),
+		pattern: `{"__and":[{"name_label":"a"},{"name_description":"first"}]}`,
+	},
+	{
+		name:    "and remote and local",
+		filter:  And(Eq("name_label", "xa"), HasPrefix("name_description", "f")),
+		pattern: `{"name_label":"xa"}`,
+		local:   true,
+	},
+	{
+		name:    "or remote",
+		filter:  Or(Eq("name_label", "a"), Eq("name_label", "b")),
+		pattern: `{"__or":[{"name_label":"a"},{"name_label":"b"}]}`,
+	},
+	{
+		name:   "or remote and local",
+		filter: Or(Eq("name_label", "a"), HasPrefix("name_label", "x")),
+		local:  true,
+	},
+	{
+		name:    "not remote",
+		filter:  Not(Eq("name_label", "a")),
+		pattern: `{"__not":{"name_label":"a"}}`,
+	},
+	{
+		name:   "not local",
+		filter: Not(HasPrefix("name_label", "x")),
+		local:  true,
+	},
+	{
+		name:   "not and remote and local",
+		filter: Not(And(Eq("name_label", "a"), HasPrefix("name_label", "x"))),
+		local:  true,
+	},
+	{
+		name:   "not or remote and local",
+		filter: Not(Or(Eq("name_label", "a"), MatchesRegexp("name_label", regexp.MustCompile("b$")))),
+		local:  true,
+	},
+	{
+		name:    "and not remote and local",
+		filter:  And(Not(Eq("name_label", "a")), Not(HasPrefix("name_label", "x"))),
+		pattern: `{"__not":{"name_label":"a"}}`,
+		local:   true,
+	},
+	{
+		name:    "and not and remote and local",
+		filter:  And(Eq("name_description", "first"), Not(And(Eq("name_label", "xa"), HasPrefix("name_label", "x")))),
+		pattern: `{"name_description":"first"}`,
+		local:   true,
+	},
+	{
+		name:   "or not and remote and local",
+		filter: Or(Eq("name_label", "b"), Not(And(Eq("name_label", "a"), HasPrefix("name_label", "x")))),
+		local:  true,
+	},
+	{
+		name:    "not not remote",
+		filter:  Not(Not(Eq("name_label", "a"))),
+		pattern: `{"__not":{"__not":{"name_label":"a"}}}`,
+	},
+}
+
+func TestFilterPattern(t *testing.T) {
+	for _, test := range queryTests {
+		t.Run(test.name, func(t *testing.T) {
+			pattern, ok := test.filter.pattern()
+			if len(test.pattern) == 0 {
+				if ok {
+					t.Fatalf("expected no pattern, got %v", pattern)
+				}
+			} else {
+				if !ok {
+					t.Fatalf("expected pattern %s, got none", test.pattern)
+				}
+				b, err := json.Marshal(pattern)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(b) != test.pattern {
+					t.Fatalf("expected pattern %s, got %s", test.pattern, b)
+				}
+			}
+
+			if local := test.filter.local(); local != test.local {
+				t.Fatalf("expected local to be %t, got %t", test.local, local)
+			}
+		})
+	}
+}
+
+func TestListWithFilter(t *testing.T) {
+	s := xotest.NewServer()
+	defer s.Close()
+	s.AddUser("admin", testPassword)
+	pool := s.AddPool("pool")
+	for _, name := range []string{"a", "b", "xa", "xb"} {
+		id := s.AddNetwork(pool, name)
+		description := "second"
+		if name == "a" || name == "xa" {
+			description = "first"
+		}
+		s.UpdateObject(id, map[string]interface{}{"name_description": description})
+	}
+
+	c, err := NewClient(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx := context.Background()
+	if err := c.SignIn(ctx, "admin", testPassword); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range queryTests {
+		t.Run(test.name, func(t *testing.T) {
+			// the objects matching the filter on the client alone
+			var want []string
+			for _, obj := range s.ObjectsOfType("network") {
+				if test.filter.Match(obj) {
+					want = append(want, obj["name_label"].(string))
+				}
+			}
+			sort.Strings(want)
+
+			networks, err := c.ListNetworks(ctx, test.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, network := range networks {
+				got = append(got, network.Name)
+			}
+			sort.Strings(got)
+
+			if len(want) == 0 {
+				t.Fatalf("test filter %s matches no network", test.filter)
+			}
+			if len(got) != len(want) {
+				t.Fatalf("expected networks %v, got %v", want, got)
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Fatalf("expected networks %v, got %v", want, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package xo_client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"time"
+
+	gws "github.com/gorilla/websocket"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+const (
+	reconnectAttempts       = 5
+	reconnectInitialBackoff = 500 * time.Millisecond
+	reconnectMaxBackoff     = 30 * time.Second
+)
+
+// idempotentMethods are safe to send again when the connection drops before a response is received
+var idempotentMethods = map[string]bool{
+	"xo.getAllObjects": true,
+}
+
+func (c *Client) call(ctx context.Context, method string, params, result interface{}) error {
+	// the notification for a change can arrive after the call returns, so the read that
+	// usually follows goes to the server for the objects the call touched
+	if c.cache != nil && !idempotentMethods[method] {
+		c.cache.markStale(params)
+	}
+
+	if c.replayer != nil {
+		return c.replayer.replay(method, params, result)
+	}
+
+	raw, err := c.send(ctx, method, params)
+
+	if c.recorder != nil {
+		c.recorder.record(method, params, raw, err)
+	}
+
+	// the caller's deadline ran out, say what was still pending when it did
+	if err != nil && ctx.Err() != nil {
+		return fmt.Errorf("%s was still pending when the operation timed out or was cancelled: %w", method, err)
+	}
+
+	if err != nil || result == nil || len(raw) == 0 {
+		return err
+	}
+
+	return json.Unmarshal(raw, result)
+}
+
+// send makes the call on the current connection, reconnecting when it has been lost, and
+// returns the raw response
+func (c *Client) send(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
+	for attempt := 0; ; attempt++ {
+		rpcConn, err := c.conn(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		release, err := c.limiter.acquire(ctx, method)
+		if err != nil {
+			return nil, err
+		}
+
+		var raw json.RawMessage
+		start := time.Now()
+		err = rpcConn.Call(ctx, method, params, &raw)
+		release()
+		c.logCall(method, params, attempt, time.Since(start), raw, err)
+
+		if err == nil {
+			return raw, nil
+		}
+
+		if ctx.Err() != nil || !isConnectionError(err) {
+			var rpcErr *jsonrpc2.Error
+			if errors.As(err, &rpcErr) {
+				return nil, &RPCError{Method: method, Err: rpcErr}
+			}
+			return nil, err
+		}
+
+		// make sure the dead connection is torn down so the next call redials
+		rpcConn.Close()
+		select {
+		case <-rpcConn.DisconnectNotify():
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		if !idempotentMethods[method] || attempt >= reconnectAttempts {
+			return nil, err
+		}
+
+		log.Printf("[DEBUG] xo_client: connection lost during %s, retrying: %v", method, err)
+	}
+}
+
+// conn returns the current connection, redialing and signing in again if it has been lost
+func (c *Client) conn(ctx context.Context) (*jsonrpc2.Conn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, jsonrpc2.ErrClosed
+	}
+
+	select {
+	case <-c.rpcConn.DisconnectNotify():
+	default:
+		return c.rpcConn, nil
+	}
+
+	rpcConn, err := c.reconnect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.rpcConn = rpcConn
+
+	// notifications were missed while disconnected
+	if c.cache != nil {
+		c.cache.invalidate()
+	}
+
+	return rpcConn, nil
+}
+
+func (c *Client) reconnect(ctx context.Context) (*jsonrpc2.Conn, error) {
+	var err error
+	backoff := reconnectInitialBackoff
+
+	for attempt := 0; attempt < reconnectAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+
+			backoff *= 2
+			if backoff > reconnectMaxBackoff {
+				backoff = reconnectMaxBackoff
+			}
+		}
+
+		log.Printf("[DEBUG] xo_client: reconnecting to %s (attempt %d)", c.url.Host, attempt+1)
+
+		var rpcConn *jsonrpc2.Conn
+		rpcConn, err = c.dial(ctx)
+		if err != nil {
+			continue
+		}
+
+		if len(c.signInMethod) > 0 {
+			var reply json.RawMessage
+			start := time.Now()
+			err = rpcConn.Call(ctx, c.signInMethod, c.signInParams, &reply)
+			c.logCall(c.signInMethod, c.signInParams, 0, time.Since(start), reply, err)
+			if err != nil {
+				rpcConn.Close()
+
+				// the server rejected the credentials, trying again won't help
+				var rpcErr *jsonrpc2.Error
+				if errors.As(err, &rpcErr) {
+					return nil, &RPCError{Method: c.signInMethod, Err: rpcErr}
+				}
+				continue
+			}
+		}
+
+		return rpcConn, nil
+	}
+
+	return nil, fmt.Errorf("unable to reconnect to %s after %d attempts: %w", c.url.Host, reconnectAttempts, err)
+}
+
+func isConnectionError(err error) bool {
+	// context errors satisfy net.Error but say nothing about the connection
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if errors.Is(err, jsonrpc2.ErrClosed) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, gws.ErrCloseSent) {
+		return true
+	}
+
+	var closeErr *gws.CloseError
+	if errors.As(err, &closeErr) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,116 @@
+package xo_client
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/rmb938/terraform-provider-xenorchestra/xo_client/xotest"
+)
+
+func newSignedInClient(t *testing.T, s *xotest.Server) *Client {
+	t.Helper()
+
+	c, err := NewClient(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.SignIn(context.Background(), "admin", testPassword); err != nil {
+		c.Close()
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func TestReconnectRead(t *testing.T) {
+	s := xotest.NewServer()
+	defer s.Close()
+	s.AddUser("admin", testPassword)
+	pool := s.AddPool("pool")
+	networkID := s.AddNetwork(pool, "Pool-wide network")
+
+	c := newSignedInClient(t, s)
+	defer c.Close()
+
+	ctx := context.Background()
+
+	// the connection dropped between two calls
+	s.DisconnectAll()
+	if _, err := c.GetNetworkByID(ctx, networkID); err != nil {
+		t.Fatal(err)
+	}
+	if calls := len(s.Calls("session.signInWithPassword")); calls != 2 {
+		t.Fatalf("expected the sign-in to be replayed, got %d session.signInWithPassword calls", calls)
+	}
+
+	// the connection dropped while waiting for the response
+	s.InjectFault(xotest.Fault{Method: "xo.getAllObjects", Times: 1, Disconnect: true})
+	reads := len(s.Calls("xo.getAllObjects"))
+	if _, err := c.GetNetworkByID(ctx, networkID); err != nil {
+		t.Fatal(err)
+	}
+	if calls := len(s.Calls("xo.getAllObjects")) - reads; calls != 2 {
+		t.Fatalf("expected the read to be sent again, got %d xo.getAllObjects calls", calls)
+	}
+	if calls := len(s.Calls("session.signInWithPassword")); calls != 3 {
+		t.Fatalf("expected the sign-in to be replayed, got %d session.signInWithPassword calls", calls)
+	}
+}
+
+func TestReconnectMutatingCall(t *testing.T) {
+	s := xotest.NewServer()
+	defer s.Close()
+	s.AddUser("admin", testPassword)
+	pool := s.AddPool("pool")
+	sr := s.AddStorageRepository(pool, "Local storage", "ext")
+	vdiID := s.AddVDI(sr, "data", 1<<30)
+
+	c := newSignedInClient(t, s)
+	defer c.Close()
+
+	ctx := context.Background()
+	vdi, err := c.GetVDIByID(ctx, vdiID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the call may have gone through before the connection dropped
+	s.InjectFault(xotest.Fault{Method: "vdi.set", Times: 1, Disconnect: true})
+	name := "renamed"
+	if err := vdi.Update(c, ctx, &name, nil, nil); err == nil {
+		t.Fatal("expected vdi.set to fail")
+	}
+	if calls := len(s.Calls("vdi.set")); calls != 1 {
+		t.Fatalf("expected vdi.set not to be sent again, got %d vdi.set calls", calls)
+	}
+
+	// the next call reconnects
+	if err := vdi.Update(c, ctx, &name, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReconnectRejectedCredentials(t *testing.T) {
+	s := xotest.NewServer()
+	defer s.Close()
+	s.AddUser("admin", testPassword)
+	pool := s.AddPool("pool")
+	networkID := s.AddNetwork(pool, "Pool-wide network")
+
+	c := newSignedInClient(t, s)
+	defer c.Close()
+
+	// the password was changed while the connection was down
+	s.AddUser("admin", "changed")
+	s.DisconnectAll()
+
+	_, err := c.GetNetworkByID(context.Background(), networkID)
+	if !errors.Is(err, UnauthorizedError) {
+		t.Fatalf("expected UnauthorizedError, got %v", err)
+	}
+	if calls := len(s.Calls("session.signInWithPassword")); calls != 2 {
+		t.Fatalf("expected the sign-in to be replayed once, got %d session.signInWithPassword calls", calls)
+	}
+}
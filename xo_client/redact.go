@@ -0,0 +1,88 @@
+package xo_client
+
+import (
+	"encoding/json"
+)
+
+const redacted = "REDACTED"
+
+// sensitiveParams are never written to recordings or logs, wherever they appear in the params
+// or the responses
+var sensitiveParams = map[string]bool{
+	"password":            true,
+	"token":               true,
+	"authenticationToken": true,
+	// cloud-init user data regularly carries secrets
+	"cloudConfig": true,
+	"userData":    true,
+}
+
+// redactParams returns a generic copy of params with sensitive values replaced
+func redactParams(params interface{}) interface{} {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return nil
+	}
+
+	var generic interface{}
+	if err := json.Unmarshal(b, &generic); err != nil {
+		return nil
+	}
+
+	return redactValue(generic)
+}
+
+// sensitiveResults are the methods whose responses are never written to recordings beyond the
+// object ID, the signed in user is of no use to a replay
+var sensitiveResults = map[string]bool{
+	"session.signInWithPassword": true,
+	"session.signInWithToken":    true,
+}
+
+// redactResult returns result with sensitive values replaced
+func redactResult(method string, result json.RawMessage) json.RawMessage {
+	if len(result) == 0 {
+		return result
+	}
+
+	var generic interface{}
+	if err := json.Unmarshal(result, &generic); err != nil {
+		return nil
+	}
+
+	if sensitiveResults[method] {
+		if obj, ok := generic.(map[string]interface{}); ok {
+			for k := range obj {
+				if k != "id" {
+					obj[k] = redacted
+				}
+			}
+		} else {
+			generic = redacted
+		}
+	}
+
+	b, err := json.Marshal(redactValue(generic))
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+func redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			if sensitiveParams[k] && item != nil {
+				v[k] = redacted
+				continue
+			}
+			v[k] = redactValue(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactValue(item)
+		}
+	}
+	return v
+}
@@ -0,0 +1,126 @@
+package xo_client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// RetryPolicy controls how mutating calls that fail with a transient XAPI error are retried
+type RetryPolicy struct {
+	// MaxAttempts is how often a call is made in total, 1 disables retrying
+	MaxAttempts int
+	// InitialBackoff is waited before the first retry, it doubles for every following one
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// RetryableCodes are the XAPI error codes worth retrying
+	RetryableCodes []string
+	// MethodRetryableCodes replaces RetryableCodes for a method, a method listed without codes
+	// is never retried
+	MethodRetryableCodes map[string][]string
+}
+
+// DefaultRetryPolicy retries races XAPI reports while other operations are in flight
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: 2 * time.Second,
+	MaxBackoff:     30 * time.Second,
+	RetryableCodes: []string{
+		"VM_BAD_POWER_STATE",
+		"OTHER_OPERATION_IN_PROGRESS",
+		"HOST_NOT_ENOUGH_FREE_MEMORY",
+	},
+	MethodRetryableCodes: map[string][]string{
+		// a VM in the wrong power state for these won't get into the right one by waiting
+		"vm.start": {
+			"OTHER_OPERATION_IN_PROGRESS",
+			"HOST_NOT_ENOUGH_FREE_MEMORY",
+		},
+		"vm.stop": {
+			"OTHER_OPERATION_IN_PROGRESS",
+		},
+		// a failed create may have left objects behind, retrying it would duplicate them
+		"vm.create":   nil,
+		"disk.create": nil,
+	},
+}
+
+// WithRetryPolicy replaces DefaultRetryPolicy
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(c *Client) error {
+		c.retryPolicy = policy
+		return nil
+	}
+}
+
+func (p RetryPolicy) retryable(method string, err error) bool {
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) {
+		return false
+	}
+
+	retryableCodes := p.RetryableCodes
+	if methodCodes, ok := p.MethodRetryableCodes[method]; ok {
+		retryableCodes = methodCodes
+	}
+
+	code := rpcErr.XapiCode()
+	for _, retryableCode := range retryableCodes {
+		if code == retryableCode {
+			return true
+		}
+	}
+	return false
+}
+
+// callWithRetry makes a call that changes objects, retrying it according to the retry policy
+func (c *Client) callWithRetry(ctx context.Context, method string, params, result interface{}) error {
+	backoff := c.retryPolicy.InitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := c.call(ctx, method, params, result)
+		if err == nil || attempt >= c.retryPolicy.MaxAttempts || !c.retryPolicy.retryable(method, err) {
+			return err
+		}
+
+		log.Printf("[DEBUG] xo_client: %s failed (attempt %d of %d), retrying in %s: %v", method, attempt, c.retryPolicy.MaxAttempts, backoff, err)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return fmt.Errorf("%s was still being retried when the operation timed out or was cancelled, last error: %v: %w", method, err, ctx.Err())
+		}
+
+		backoff *= 2
+		if c.retryPolicy.MaxBackoff > 0 && backoff > c.retryPolicy.MaxBackoff {
+			backoff = c.retryPolicy.MaxBackoff
+		}
+	}
+}
+
+// XapiCode returns the XAPI error code of an error XO forwarded from XAPI, like
+// VM_BAD_POWER_STATE, or an empty string for XO's own errors
+func (e *RPCError) XapiCode() string {
+	if e.Err.Data != nil {
+		var data struct {
+			Code string `json:"code"`
+		}
+		if json.Unmarshal(*e.Err.Data, &data) == nil && len(data.Code) > 0 {
+			return data.Code
+		}
+	}
+
+	// older versions only put it in the message, like VM_BAD_POWER_STATE(OpaqueRef:..., halted, running)
+	code := e.Err.Message
+	if i := strings.IndexByte(code, '('); i >= 0 {
+		code = code[:i]
+	}
+	if len(code) > 0 && strings.ToUpper(code) == code && !strings.ContainsAny(code, " :") {
+		return code
+	}
+	return ""
+}
@@ -0,0 +1,126 @@
+package xo_client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+
+	"github.com/rmb938/terraform-provider-xenorchestra/xo_client/xotest"
+)
+
+func TestRetry(t *testing.T) {
+	s := xotest.NewServer()
+	defer s.Close()
+	s.AddUser("admin", testPassword)
+	pool := s.AddPool("pool")
+	sr := s.AddStorageRepository(pool, "Local storage", "ext")
+	templateID := s.AddTemplate(pool, "Debian Buster 10", xotest.TemplateDisk{StorageRepositoryID: sr, Size: 10 << 30})
+
+	policy := DefaultRetryPolicy
+	policy.MaxAttempts = 3
+	policy.InitialBackoff = time.Millisecond
+
+	c, err := NewClient(s.URL, WithRetryPolicy(policy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx := context.Background()
+	if err := c.SignIn(ctx, "admin", testPassword); err != nil {
+		t.Fatal(err)
+	}
+
+	template, err := c.GetTemplateByID(ctx, templateID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vm, err := c.CreateVirtualMachine(ctx, "vm", "", template, 1, 1<<30, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// transient errors are retried until the call goes through
+	s.InjectFault(xotest.Fault{Method: "vm.start", Times: 2, Err: xotest.XapiError("OTHER_OPERATION_IN_PROGRESS", "VM", vm.ID)})
+	if err := vm.Start(c, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if calls := len(s.Calls("vm.start")); calls != 3 {
+		t.Fatalf("expected 3 vm.start calls, got %d", calls)
+	}
+
+	// up to MaxAttempts
+	s.InjectFault(xotest.Fault{Method: "vm.stop", Times: 3, Err: xotest.XapiError("OTHER_OPERATION_IN_PROGRESS", "VM", vm.ID)})
+	err = vm.Stop(c, ctx, true)
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.XapiCode() != "OTHER_OPERATION_IN_PROGRESS" {
+		t.Fatalf("expected the last error to be returned, got %v", err)
+	}
+	if calls := len(s.Calls("vm.stop")); calls != 3 {
+		t.Fatalf("expected 3 vm.stop calls, got %d", calls)
+	}
+
+	// other errors are returned right away
+	s.InjectFault(xotest.Fault{Method: "vm.delete", Times: 1, Err: xotest.XapiError("VM_IS_TEMPLATE")})
+	if err := vm.Delete(c, ctx); err == nil {
+		t.Fatal("expected vm.delete to fail")
+	}
+	if calls := len(s.Calls("vm.delete")); calls != 1 {
+		t.Fatalf("expected 1 vm.delete call, got %d", calls)
+	}
+
+	// codes are retryable per method
+	s.InjectFault(xotest.Fault{Method: "vm.start", Times: 1, Err: xotest.XapiError("VM_BAD_POWER_STATE", vm.ID, "halted", "running")})
+	if err := vm.Start(c, ctx); err == nil {
+		t.Fatal("expected vm.start to fail")
+	}
+	if calls := len(s.Calls("vm.start")); calls != 4 {
+		t.Fatalf("expected VM_BAD_POWER_STATE not to be retried on vm.start, got %d vm.start calls", calls)
+	}
+
+	// creates are never retried
+	s.InjectFault(xotest.Fault{Method: "vm.create", Times: 1, Err: xotest.XapiError("OTHER_OPERATION_IN_PROGRESS", "VM", vm.ID)})
+	if _, err := c.CreateVirtualMachine(ctx, "vm", "", template, 1, 1<<30, nil, nil, nil, nil); err == nil {
+		t.Fatal("expected vm.create to fail")
+	}
+	if calls := len(s.Calls("vm.create")); calls != 2 {
+		t.Fatalf("expected vm.create not to be retried, got %d vm.create calls", calls)
+	}
+
+	storageRepository, err := c.GetStorageRepositoryByID(ctx, sr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.InjectFault(xotest.Fault{Method: "disk.create", Times: 1, Err: xotest.XapiError("OTHER_OPERATION_IN_PROGRESS", "SR", sr)})
+	if _, err := c.CreateVDI(ctx, "data", VDIModeRW, 1<<30, storageRepository); err == nil {
+		t.Fatal("expected disk.create to fail")
+	}
+	if calls := len(s.Calls("disk.create")); calls != 1 {
+		t.Fatalf("expected disk.create not to be retried, got %d disk.create calls", calls)
+	}
+}
+
+func TestXapiCode(t *testing.T) {
+	cases := map[string]*jsonrpc2.Error{
+		"VM_BAD_POWER_STATE": xotest.XapiError("VM_BAD_POWER_STATE", "OpaqueRef:1", "halted", "running"),
+		"OTHER_OPERATION_IN_PROGRESS": {
+			Code:    -32000,
+			Message: "OTHER_OPERATION_IN_PROGRESS(VM, OpaqueRef:1)",
+		},
+		"": {
+			Code:    xoErrorNoSuchObject,
+			Message: "no such object",
+		},
+	}
+
+	for code, err := range cases {
+		rpcErr := &RPCError{Method: "vm.start", Err: err}
+		if got := rpcErr.XapiCode(); got != code {
+			t.Errorf("%s: expected code %q, got %q", err.Message, code, got)
+		}
+	}
+}
@@ -17,45 +17,6 @@ type Template struct {
 	Pool         string       `json:"$pool"`
 }
 
-func (c *Client) GetTemplateByID(ctx context.Context, id string) (*Template, error) {
-	query := ObjectQuery{
-		"id": id,
-	}
-
-	objs, err := c.GetObjectsOfType(ctx, "VM-template", query)
-	if err != nil {
-		return nil, err
-	}
-
-	interf, err := objs.ConvertToSingle(Template{})
-	if err != nil {
-		return nil, err
-	}
-
-	template := interf.(Template)
-	return &template, nil
-}
-
-func (c *Client) GetTemplateByName(ctx context.Context, poolID, name string) (*Template, error) {
-	query := ObjectQuery{
-		"name_label": name,
-		"$pool":      poolID,
-	}
-
-	objs, err := c.GetObjectsOfType(ctx, "VM-template", query)
-	if err != nil {
-		return nil, err
-	}
-
-	interf, err := objs.ConvertToSingle(Template{})
-	if err != nil {
-		return nil, err
-	}
-
-	template := interf.(Template)
-	return &template, nil
-}
-
 func (t *Template) GetVBDs(client *Client, ctx context.Context, includeCDDrive bool) ([]VBD, error) {
 	var VBDs []VBD
 
@@ -0,0 +1,128 @@
+package xo_client
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type tlsOptions struct {
+	rootCAs            *x509.CertPool
+	certificates       []tls.Certificate
+	insecureSkipVerify bool
+	fingerprint        []byte
+}
+
+// WithCACertificates trusts the PEM encoded certificates in addition to the system roots
+func WithCACertificates(caPEM []byte) ClientOption {
+	return func(c *Client) error {
+		if c.tlsOpts.rootCAs == nil {
+			pool, err := x509.SystemCertPool()
+			if err != nil || pool == nil {
+				pool = x509.NewCertPool()
+			}
+			c.tlsOpts.rootCAs = pool
+		}
+
+		if !c.tlsOpts.rootCAs.AppendCertsFromPEM(caPEM) {
+			return fmt.Errorf("no valid certificates found in CA PEM")
+		}
+
+		return nil
+	}
+}
+
+// WithClientCertificate presents the PEM encoded certificate and key to the server
+func WithClientCertificate(certPEM, keyPEM []byte) ClientOption {
+	return func(c *Client) error {
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return fmt.Errorf("error loading client certificate: %w", err)
+		}
+
+		c.tlsOpts.certificates = append(c.tlsOpts.certificates, cert)
+		return nil
+	}
+}
+
+// WithInsecureSkipVerify disables verification of the server certificate
+func WithInsecureSkipVerify() ClientOption {
+	return func(c *Client) error {
+		c.tlsOpts.insecureSkipVerify = true
+		return nil
+	}
+}
+
+// WithCertificateFingerprint pins the server certificate to the hex encoded SHA-256
+// fingerprint. When no CA certificates are given the pin replaces chain validation, which
+// allows connecting to appliances with self-signed certificates.
+func WithCertificateFingerprint(fingerprint string) ClientOption {
+	return func(c *Client) error {
+		fingerprint = strings.ReplaceAll(strings.TrimSpace(fingerprint), ":", "")
+
+		pin, err := hex.DecodeString(fingerprint)
+		if err != nil {
+			return fmt.Errorf("invalid certificate fingerprint: %w", err)
+		}
+
+		if len(pin) != sha256.Size {
+			return fmt.Errorf("invalid certificate fingerprint: expected %d bytes, got %d", sha256.Size, len(pin))
+		}
+
+		c.tlsOpts.fingerprint = pin
+		return nil
+	}
+}
+
+func (o *tlsOptions) config() *tls.Config {
+	cfg := &tls.Config{
+		RootCAs:            o.rootCAs,
+		Certificates:       o.certificates,
+		InsecureSkipVerify: o.insecureSkipVerify,
+	}
+
+	if o.fingerprint == nil {
+		return cfg
+	}
+
+	// the default verification is replaced so self-signed certificates can be pinned
+	verifyChain := o.rootCAs != nil && !o.insecureSkipVerify
+	cfg.InsecureSkipVerify = true
+	cfg.VerifyConnection = func(state tls.ConnectionState) error {
+		if len(state.PeerCertificates) == 0 {
+			return errors.New("server did not present a certificate")
+		}
+
+		leaf := state.PeerCertificates[0]
+
+		if verifyChain {
+			intermediates := x509.NewCertPool()
+			for _, cert := range state.PeerCertificates[1:] {
+				intermediates.AddCert(cert)
+			}
+
+			_, err := leaf.Verify(x509.VerifyOptions{
+				DNSName:       state.ServerName,
+				Roots:         o.rootCAs,
+				Intermediates: intermediates,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		fingerprint := sha256.Sum256(leaf.Raw)
+		if !bytes.Equal(fingerprint[:], o.fingerprint) {
+			return fmt.Errorf("server certificate fingerprint %s does not match the pinned fingerprint %s", hex.EncodeToString(fingerprint[:]), hex.EncodeToString(o.fingerprint))
+		}
+
+		return nil
+	}
+
+	return cfg
+}
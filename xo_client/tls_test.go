@@ -0,0 +1,163 @@
+package xo_client
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	gws "github.com/gorilla/websocket"
+)
+
+// newTLSServer accepts websocket connections over TLS with the self-signed certificate of
+// httptest, it returns the wss:// URL, the certificate as PEM and its SHA-256 fingerprint
+func newTLSServer(t *testing.T) (*url.URL, []byte, string) {
+	t.Helper()
+
+	upgrader := gws.Upgrader{}
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		for {
+			if _, _, err := ws.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	// the rejected handshakes are expected
+	s.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	s.StartTLS()
+	t.Cleanup(s.Close)
+
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.Scheme = "wss"
+
+	cert := s.Certificate()
+	fingerprint := sha256.Sum256(cert.Raw)
+	return u, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), hex.EncodeToString(fingerprint[:])
+}
+
+// otherCAPEM returns a CA certificate that didn't sign the server certificate
+func otherCAPEM(t *testing.T) []byte {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Other CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestCertificateFingerprint(t *testing.T) {
+	u, serverPEM, fingerprint := newTLSServer(t)
+	wrongFingerprint := strings.Repeat("00", sha256.Size)
+
+	// colon separated like browsers show it
+	var colons []string
+	for i := 0; i < len(fingerprint); i += 2 {
+		colons = append(colons, strings.ToUpper(fingerprint[i:i+2]))
+	}
+
+	tests := []struct {
+		name string
+		opts []ClientOption
+		err  string
+	}{
+		{
+			name: "without a pin",
+			err:  "certificate signed by unknown authority",
+		},
+		{
+			name: "pin",
+			opts: []ClientOption{WithCertificateFingerprint(fingerprint)},
+		},
+		{
+			name: "colon separated pin",
+			opts: []ClientOption{WithCertificateFingerprint(strings.Join(colons, ":"))},
+		},
+		{
+			name: "wrong pin",
+			opts: []ClientOption{WithCertificateFingerprint(wrongFingerprint)},
+			err:  "does not match the pinned fingerprint",
+		},
+		{
+			name: "pin and CA",
+			opts: []ClientOption{WithCACertificates(serverPEM), WithCertificateFingerprint(fingerprint)},
+		},
+		{
+			name: "pin and other CA",
+			opts: []ClientOption{WithCACertificates(otherCAPEM(t)), WithCertificateFingerprint(fingerprint)},
+			err:  "certificate signed by unknown authority",
+		},
+		{
+			name: "wrong pin and CA",
+			opts: []ClientOption{WithCACertificates(serverPEM), WithCertificateFingerprint(wrongFingerprint)},
+			err:  "does not match the pinned fingerprint",
+		},
+		{
+			name: "invalid pin",
+			opts: []ClientOption{WithCertificateFingerprint("not a fingerprint")},
+			err:  "invalid certificate fingerprint",
+		},
+		{
+			name: "short pin",
+			opts: []ClientOption{WithCertificateFingerprint(fingerprint[:32])},
+			err:  "invalid certificate fingerprint: expected 32 bytes, got 16",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := NewClient(&url.URL{Scheme: u.Scheme, Host: u.Host}, test.opts...)
+			if len(test.err) == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				c.Close()
+				return
+			}
+
+			if err == nil {
+				c.Close()
+				t.Fatalf("expected an error containing %q", test.err)
+			}
+			if !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("expected an error containing %q, got %v", test.err, err)
+			}
+		})
+	}
+}
@@ -6,33 +6,16 @@ import (
 
 type VBD struct {
 	ID       string `json:"id"`
+	Attached bool   `json:"attached"`
 	Bootable bool   `json:"bootable"`
 	Device   string `json:"device"`
 	CDDrive  bool   `json:"is_cd_drive"`
 	Position string `json:"position"`
+	ReadOnly bool   `json:"read_only"`
 	VDI      string `json:"VDI"`
 	VM       string `json:"VM"`
 }
 
-func (c *Client) GetVBDByID(ctx context.Context, id string) (*VBD, error) {
-	query := ObjectQuery{
-		"id": id,
-	}
-
-	objs, err := c.GetObjectsOfType(ctx, "VBD", query)
-	if err != nil {
-		return nil, err
-	}
-
-	interf, err := objs.ConvertToSingle(VBD{})
-	if err != nil {
-		return nil, err
-	}
-
-	VBD := interf.(VBD)
-	return &VBD, nil
-}
-
 func (vbd *VBD) GetVDI(client *Client, ctx context.Context) (*VDI, error) {
 	return client.GetVDIByID(ctx, vbd.VDI)
 }
@@ -42,7 +25,15 @@ func (vbd *VBD) Delete(client *Client, ctx context.Context) error {
 		"id": vbd.ID,
 	}
 
-	return client.rpcConn.Call(ctx, "vbd.delete", params, nil)
+	return client.callWithRetry(ctx, "vbd.delete", params, nil)
+}
+
+func (vbd *VBD) Connect(client *Client, ctx context.Context) error {
+	params := map[string]interface{}{
+		"id": vbd.ID,
+	}
+
+	return client.callWithRetry(ctx, "vbd.connect", params, nil)
 }
 
 func (vbd *VBD) Disconnect(client *Client, ctx context.Context) error {
@@ -50,5 +41,14 @@ func (vbd *VBD) Disconnect(client *Client, ctx context.Context) error {
 		"id": vbd.ID,
 	}
 
-	return client.rpcConn.Call(ctx, "vbd.disconnect", params, nil)
+	return client.callWithRetry(ctx, "vbd.disconnect", params, nil)
+}
+
+func (vbd *VBD) SetBootable(client *Client, ctx context.Context, bootable bool) error {
+	params := map[string]interface{}{
+		"vbd":      vbd.ID,
+		"bootable": bootable,
+	}
+
+	return client.callWithRetry(ctx, "vbd.setBootable", params, nil)
 }
@@ -12,12 +12,13 @@ var (
 )
 
 type VDI struct {
-	ID                  string `json:"id"`
-	Name                string `json:"name_label"`
-	Description         string `json:"name_description"`
-	Size                int    `json:"size"`
-	StorageRepositoryID string `json:"$SR"`
-	Pool                string `json:"$pool"`
+	ID                  string   `json:"id"`
+	Name                string   `json:"name_label"`
+	Description         string   `json:"name_description"`
+	Size                int      `json:"size"`
+	StorageRepositoryID string   `json:"$SR"`
+	VBDs                []string `json:"$VBDs"`
+	Pool                string   `json:"$pool"`
 }
 
 func (c *Client) CreateVDI(ctx context.Context, name string, mode VDIMode, size int, storageRepository *StorageRepository) (*VDI, error) {
@@ -29,7 +30,7 @@ func (c *Client) CreateVDI(ctx context.Context, name string, mode VDIMode, size
 	}
 
 	var vdiID string
-	err := c.rpcConn.Call(ctx, "disk.create", params, &vdiID)
+	err := c.callWithRetry(ctx, "disk.create", params, &vdiID)
 	if err != nil {
 		return nil, err
 	}
@@ -37,45 +38,6 @@ func (c *Client) CreateVDI(ctx context.Context, name string, mode VDIMode, size
 	return c.GetVDIByID(ctx, vdiID)
 }
 
-func (c *Client) GetVDIByName(ctx context.Context, storageRepositoryID, name string) (*VDI, error) {
-	query := ObjectQuery{
-		"$SR":        storageRepositoryID,
-		"name_label": name,
-	}
-
-	objs, err := c.GetObjectsOfType(ctx, "VDI", query)
-	if err != nil {
-		return nil, err
-	}
-
-	interf, err := objs.ConvertToSingle(VDI{})
-	if err != nil {
-		return nil, err
-	}
-
-	VDI := interf.(VDI)
-	return &VDI, nil
-}
-
-func (c *Client) GetVDIByID(ctx context.Context, id string) (*VDI, error) {
-	query := ObjectQuery{
-		"id": id,
-	}
-
-	objs, err := c.GetObjectsOfType(ctx, "VDI", query)
-	if err != nil {
-		return nil, err
-	}
-
-	interf, err := objs.ConvertToSingle(VDI{})
-	if err != nil {
-		return nil, err
-	}
-
-	VDI := interf.(VDI)
-	return &VDI, nil
-}
-
 func (vdi *VDI) Update(client *Client, ctx context.Context, name, description *string, size *int) error {
 	params := map[string]interface{}{
 		"id": vdi.ID,
@@ -93,7 +55,7 @@ func (vdi *VDI) Update(client *Client, ctx context.Context, name, description *s
 		params["size"] = size
 	}
 
-	return client.rpcConn.Call(ctx, "vdi.set", params, nil)
+	return client.callWithRetry(ctx, "vdi.set", params, nil)
 }
 
 func (vdi *VDI) Delete(client *Client, ctx context.Context) error {
@@ -101,5 +63,26 @@ func (vdi *VDI) Delete(client *Client, ctx context.Context) error {
 		"id": vdi.ID,
 	}
 
-	return client.rpcConn.Call(ctx, "vdi.delete", params, nil)
+	return client.callWithRetry(ctx, "vdi.delete", params, nil)
+}
+
+// GetMode returns the mode the VDI is attached with, XAPI keeps the mode on the VBDs so a VDI
+// that isn't attached to any VM is RW
+func (vdi *VDI) GetMode(client *Client, ctx context.Context) (VDIMode, error) {
+	if len(vdi.VBDs) == 0 {
+		return VDIModeRW, nil
+	}
+
+	for _, vbdID := range vdi.VBDs {
+		vbd, err := client.GetVBDByID(ctx, vbdID)
+		if err != nil {
+			return "", err
+		}
+
+		if !vbd.ReadOnly {
+			return VDIModeRW, nil
+		}
+	}
+
+	return VDIModeRO, nil
 }
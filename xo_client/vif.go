@@ -5,30 +5,91 @@ import (
 )
 
 type VIF struct {
-	ID        string `json:"id"`
-	Device    string `json:"device"`
-	MAC       string `json:"mac"`
-	Attached  bool   `json:"attached"`
-	NetworkID string `json:"$network"`
+	ID                   string   `json:"id"`
+	Device               string   `json:"device"`
+	MAC                  string   `json:"mac"`
+	MTU                  int      `json:"MTU"`
+	Attached             bool     `json:"attached"`
+	NetworkID            string   `json:"$network"`
+	VM                   string   `json:"$VM"`
+	LockingMode          string   `json:"lockingMode"`
+	AllowedIPv4Addresses []string `json:"allowedIpv4Addresses"`
+	AllowedIPv6Addresses []string `json:"allowedIpv6Addresses"`
+	// RateLimit is in kilobytes per second, 0 when the VIF isn't rate limited
+	RateLimit int `json:"rateLimit"`
 }
 
-func (c *Client) GetVIFByID(ctx context.Context, id string) (*VIF, error) {
-	query := ObjectQuery{
-		"id": id,
+// VIFSettings are the settings of a VIF that can be changed without recreating it
+type VIFSettings struct {
+	// LockingMode is one of network_default, locked, unlocked or disabled
+	LockingMode          string
+	AllowedIPv4Addresses []string
+	AllowedIPv6Addresses []string
+	// RateLimit is in kilobytes per second, 0 removes the limit
+	RateLimit int
+}
+
+// Settings returns the current settings of the VIF
+func (vif *VIF) Settings() VIFSettings {
+	return VIFSettings{
+		LockingMode:          vif.LockingMode,
+		AllowedIPv4Addresses: vif.AllowedIPv4Addresses,
+		AllowedIPv6Addresses: vif.AllowedIPv6Addresses,
+		RateLimit:            vif.RateLimit,
 	}
+}
+
+// Equal reports whether both settings are the same, the order of the allowed addresses
+// doesn't matter
+func (s VIFSettings) Equal(other VIFSettings) bool {
+	return s.LockingMode == other.LockingMode &&
+		s.RateLimit == other.RateLimit &&
+		sameStrings(s.AllowedIPv4Addresses, other.AllowedIPv4Addresses) &&
+		sameStrings(s.AllowedIPv6Addresses, other.AllowedIPv6Addresses)
+}
+
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	counts := map[string]int{}
+	for _, s := range a {
+		counts[s]++
+	}
+	for _, s := range b {
+		counts[s]--
+		if counts[s] < 0 {
+			return false
+		}
+	}
+
+	return true
+}
 
-	objs, err := c.GetObjectsOfType(ctx, "VIF", query)
-	if err != nil {
-		return nil, err
+// Set changes the settings of the VIF
+func (vif *VIF) Set(client *Client, ctx context.Context, settings VIFSettings) error {
+	params := map[string]interface{}{
+		"id":                   vif.ID,
+		"lockingMode":          settings.LockingMode,
+		"allowedIpv4Addresses": nonNilStrings(settings.AllowedIPv4Addresses),
+		"allowedIpv6Addresses": nonNilStrings(settings.AllowedIPv6Addresses),
+		"rateLimit":            nil,
 	}
 
-	interf, err := objs.ConvertToSingle(VIF{})
-	if err != nil {
-		return nil, err
+	if settings.RateLimit > 0 {
+		params["rateLimit"] = settings.RateLimit
 	}
 
-	VIF := interf.(VIF)
-	return &VIF, nil
+	return client.callWithRetry(ctx, "vif.set", params, nil)
+}
+
+// nonNilStrings makes sure an empty list is sent as [] instead of null
+func nonNilStrings(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
 }
 
 func (vif *VIF) Delete(client *Client, ctx context.Context) error {
@@ -36,7 +97,15 @@ func (vif *VIF) Delete(client *Client, ctx context.Context) error {
 		"id": vif.ID,
 	}
 
-	return client.rpcConn.Call(ctx, "vif.delete", params, nil)
+	return client.callWithRetry(ctx, "vif.delete", params, nil)
+}
+
+func (vif *VIF) Connect(client *Client, ctx context.Context) error {
+	params := map[string]interface{}{
+		"id": vif.ID,
+	}
+
+	return client.callWithRetry(ctx, "vif.connect", params, nil)
 }
 
 func (vif *VIF) Disconnect(client *Client, ctx context.Context) error {
@@ -44,5 +113,5 @@ func (vif *VIF) Disconnect(client *Client, ctx context.Context) error {
 		"id": vif.ID,
 	}
 
-	return client.rpcConn.Call(ctx, "vif.disconnect", params, nil)
+	return client.callWithRetry(ctx, "vif.disconnect", params, nil)
 }
@@ -2,11 +2,19 @@ package xo_client
 
 import (
 	"context"
+	"fmt"
+	"time"
 )
 
+// powerStatePollInterval is how often WaitForPowerState looks at the VM
+var powerStatePollInterval = time.Second
+
 type VirtualMachineVIF struct {
 	NetworkID string `json:"network"`
-	// TODO: mac
+	// MAC is generated by XAPI when empty
+	MAC string `json:"mac,omitempty"`
+	// MTU is the one of the network when 0
+	MTU int `json:"mtu,omitempty"`
 }
 
 type VirtualMachineDisk struct {
@@ -44,15 +52,19 @@ type VirtualMachine struct {
 	PVDriversDetected bool                 `json:"pvDriversDetected"`
 	VBDs              []string             `json:"$VBDs"`
 	Pool              string               `json:"$pool"`
+	// Other is the VM's other_config
+	Other map[string]string `json:"other"`
 }
 
-func (c *Client) CreateVirtualMachine(ctx context.Context, name string, description string, template *Template, cpus, memory int, installation *VirtualMachineInstallation, vmd, existingDisk *VirtualMachineDisk, networks []Network) (*VirtualMachine, error) {
+// BaseTemplateName returns the name of the template the VM was created from when XAPI
+// recorded it
+func (vm *VirtualMachine) BaseTemplateName() string {
+	return vm.Other["base_template_name"]
+}
 
-	vifs := make([]VirtualMachineVIF, 0)
-	for _, network := range networks {
-		vifs = append(vifs, VirtualMachineVIF{
-			NetworkID: network.ID,
-		})
+func (c *Client) CreateVirtualMachine(ctx context.Context, name string, description string, template *Template, cpus, memory int, installation *VirtualMachineInstallation, vmd, existingDisk *VirtualMachineDisk, vifs []VirtualMachineVIF) (*VirtualMachine, error) {
+	if vifs == nil {
+		vifs = make([]VirtualMachineVIF, 0)
 	}
 
 	params := map[string]interface{}{
@@ -80,7 +92,7 @@ func (c *Client) CreateVirtualMachine(ctx context.Context, name string, descript
 	}
 
 	var virtualMachineID string
-	err := c.rpcConn.Call(ctx, "vm.create", params, &virtualMachineID)
+	err := c.callWithRetry(ctx, "vm.create", params, &virtualMachineID)
 	if err != nil {
 		return nil, err
 	}
@@ -88,25 +100,6 @@ func (c *Client) CreateVirtualMachine(ctx context.Context, name string, descript
 	return c.GetVirtualMachineByID(ctx, virtualMachineID)
 }
 
-func (c *Client) GetVirtualMachineByID(ctx context.Context, id string) (*VirtualMachine, error) {
-	query := ObjectQuery{
-		"id": id,
-	}
-
-	objs, err := c.GetObjectsOfType(ctx, "VM", query)
-	if err != nil {
-		return nil, err
-	}
-
-	interf, err := objs.ConvertToSingle(VirtualMachine{})
-	if err != nil {
-		return nil, err
-	}
-
-	virtualMachine := interf.(VirtualMachine)
-	return &virtualMachine, nil
-}
-
 func (vm *VirtualMachine) GetBootDisk(client *Client, ctx context.Context) (*VDI, error) {
 
 	for _, vbdID := range vm.VBDs {
@@ -208,13 +201,80 @@ func (vm *VirtualMachine) GetVIFs(client *Client, ctx context.Context) ([]VIF, e
 	return vifs, nil
 }
 
-func (vm *VirtualMachine) AttachDisk(client *Client, ctx context.Context, vdi *VDI) error {
+// AttachDisk creates a VBD for vdi and returns it, an empty mode attaches the disk RW and an
+// empty position takes the first free one
+func (vm *VirtualMachine) AttachDisk(client *Client, ctx context.Context, vdi *VDI, mode VDIMode, bootable bool, position string) (*VBD, error) {
 	params := map[string]interface{}{
 		"vdi": vdi.ID,
 		"vm":  vm.ID,
 	}
 
-	return client.rpcConn.Call(ctx, "vm.attachDisk", params, nil)
+	if len(mode) > 0 {
+		params["mode"] = mode
+	}
+
+	if bootable {
+		params["bootable"] = true
+	}
+
+	if len(position) > 0 {
+		params["position"] = position
+	}
+
+	err := client.callWithRetry(ctx, "vm.attachDisk", params, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// vm.attachDisk doesn't return the VBD, it is the one of the VDI on this VM
+	attached, err := client.GetVDIByID(ctx, vdi.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, vbdID := range attached.VBDs {
+		vbd, err := client.GetVBDByID(ctx, vbdID)
+		if err != nil {
+			return nil, err
+		}
+
+		if vbd.VM == vm.ID {
+			return vbd, nil
+		}
+	}
+
+	return nil, fmt.Errorf("VBD of VDI %s on VM %s: %w", vdi.ID, vm.ID, NotFoundError)
+}
+
+// SetCPUs changes the number of vCPUs and the max vCPUs, the max can only be changed while the
+// VM is halted
+func (vm *VirtualMachine) SetCPUs(client *Client, ctx context.Context, cpus, maxCPUs int) error {
+	params := map[string]interface{}{
+		"id":   vm.ID,
+		"CPUs": cpus,
+	}
+
+	if maxCPUs != vm.CPU.Max {
+		params["cpusMax"] = maxCPUs
+	}
+
+	return client.callWithRetry(ctx, "vm.set", params, nil)
+}
+
+// SetMemory changes the static max and the dynamic range of the memory in bytes, the static
+// max can only be changed while the VM is halted
+func (vm *VirtualMachine) SetMemory(client *Client, ctx context.Context, staticMax, dynamicMin, dynamicMax int) error {
+	params := map[string]interface{}{
+		"id":        vm.ID,
+		"memoryMin": dynamicMin,
+		"memoryMax": dynamicMax,
+	}
+
+	if len(vm.Memory.Static) < 2 || staticMax != vm.Memory.Static[1] {
+		params["memoryStaticMax"] = staticMax
+	}
+
+	return client.callWithRetry(ctx, "vm.set", params, nil)
 }
 
 func (vm *VirtualMachine) Update(client *Client, ctx context.Context, name, description *string) error {
@@ -230,7 +290,7 @@ func (vm *VirtualMachine) Update(client *Client, ctx context.Context, name, desc
 		params["name_description"] = description
 	}
 
-	return client.rpcConn.Call(ctx, "vm.set", params, nil)
+	return client.callWithRetry(ctx, "vm.set", params, nil)
 }
 
 func (vm *VirtualMachine) Delete(client *Client, ctx context.Context) error {
@@ -238,7 +298,7 @@ func (vm *VirtualMachine) Delete(client *Client, ctx context.Context) error {
 		"id": vm.ID,
 	}
 
-	return client.rpcConn.Call(ctx, "vm.delete", params, nil)
+	return client.callWithRetry(ctx, "vm.delete", params, nil)
 }
 
 func (vm *VirtualMachine) Stop(client *Client, ctx context.Context, force bool) error {
@@ -247,7 +307,7 @@ func (vm *VirtualMachine) Stop(client *Client, ctx context.Context, force bool)
 		"force": force,
 	}
 
-	return client.rpcConn.Call(ctx, "vm.stop", params, nil)
+	return client.callWithRetry(ctx, "vm.stop", params, nil)
 }
 
 func (vm *VirtualMachine) Start(client *Client, ctx context.Context) error {
@@ -255,14 +315,60 @@ func (vm *VirtualMachine) Start(client *Client, ctx context.Context) error {
 		"id": vm.ID,
 	}
 
-	return client.rpcConn.Call(ctx, "vm.start", params, nil)
+	return client.callWithRetry(ctx, "vm.start", params, nil)
 }
 
-func (vm *VirtualMachine) AttachNetwork(client *Client, ctx context.Context, network *Network) error {
+// AttachNetwork creates a VIF on network and returns it, an empty position takes the first
+// free device, XAPI generates the MAC when mac is empty and mtu 0 uses the MTU of the network
+func (vm *VirtualMachine) AttachNetwork(client *Client, ctx context.Context, network *Network, position, mac string, mtu int) (*VIF, error) {
 	params := map[string]interface{}{
 		"vm":      vm.ID,
 		"network": network.ID,
 	}
 
-	return client.rpcConn.Call(ctx, "vm.createInterface", params, nil)
+	if len(position) > 0 {
+		params["position"] = position
+	}
+
+	if len(mac) > 0 {
+		params["mac"] = mac
+	}
+
+	if mtu > 0 {
+		params["mtu"] = mtu
+	}
+
+	var vifID string
+	err := client.callWithRetry(ctx, "vm.createInterface", params, &vifID)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.GetVIFByID(ctx, vifID)
+}
+
+// WaitForPowerState waits until the VM reaches powerState, like Running or Halted, or ctx
+// is done
+func (vm *VirtualMachine) WaitForPowerState(client *Client, ctx context.Context, powerState string) error {
+	ticker := time.NewTicker(powerStatePollInterval)
+	defer ticker.Stop()
+
+	for {
+		current, err := client.GetVirtualMachineByID(ctx, vm.ID)
+		if err != nil {
+			return err
+		}
+
+		vm.PowerState = current.PowerState
+		vm.PVDriversDetected = current.PVDriversDetected
+		if current.PowerState == powerState {
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for VM %s to become %s, it is still %s: %w", vm.ID, powerState, vm.PowerState, ctx.Err())
+		}
+	}
 }
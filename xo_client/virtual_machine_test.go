@@ -0,0 +1,71 @@
+package xo_client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rmb938/terraform-provider-xenorchestra/xo_client/xotest"
+)
+
+func TestWaitForPowerState(t *testing.T) {
+	defer func(interval time.Duration) { powerStatePollInterval = interval }(powerStatePollInterval)
+	powerStatePollInterval = 10 * time.Millisecond
+
+	s := xotest.NewServer()
+	defer s.Close()
+	s.AddUser("admin", testPassword)
+	pool := s.AddPool("pool")
+	sr := s.AddStorageRepository(pool, "Local storage", "ext")
+	templateID := s.AddTemplate(pool, "Debian Buster 10", xotest.TemplateDisk{StorageRepositoryID: sr, Size: 10 << 30})
+
+	for _, cache := range []bool{false, true} {
+		var opts []ClientOption
+		if cache {
+			opts = append(opts, WithObjectCache())
+		}
+
+		c, err := NewClient(s.URL, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx := context.Background()
+		if err := c.SignIn(ctx, "admin", testPassword); err != nil {
+			t.Fatal(err)
+		}
+
+		template, err := c.GetTemplateByID(ctx, templateID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		vm, err := c.CreateVirtualMachine(ctx, "vm", "", template, 1, 1<<30, nil, nil, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the VM boots a little after the call returned
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			s.UpdateObject(vm.ID, map[string]interface{}{"power_state": "Running"})
+		}()
+
+		if err := vm.WaitForPowerState(c, ctx, "Running"); err != nil {
+			t.Fatal(err)
+		}
+		if vm.PowerState != "Running" {
+			t.Fatalf("expected the VM to be updated, got %s", vm.PowerState)
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		err = vm.WaitForPowerState(c, ctx, "Halted")
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected the wait to time out, got %v", err)
+		}
+
+		c.Close()
+	}
+}
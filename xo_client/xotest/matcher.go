@@ -0,0 +1,81 @@
+package xotest
+
+import (
+	"reflect"
+)
+
+// match implements the pattern semantics of the value-matcher package xo-server uses to
+// filter xo.getAllObjects:
+//   - {__and: [patterns]}, {__or: [patterns]} and {__not: pattern} combine patterns
+//   - an object pattern matches objects whose listed properties all match
+//   - an array pattern matches arrays holding a match for every element of the pattern
+//   - anything else has to be equal
+func match(pattern, value interface{}) bool {
+	switch p := pattern.(type) {
+	case map[string]interface{}:
+		if len(p) == 1 {
+			if sub, ok := p["__and"]; ok {
+				for _, s := range asSlice(sub) {
+					if !match(s, value) {
+						return false
+					}
+				}
+				return true
+			}
+
+			if sub, ok := p["__or"]; ok {
+				for _, s := range asSlice(sub) {
+					if match(s, value) {
+						return true
+					}
+				}
+				return false
+			}
+
+			if sub, ok := p["__not"]; ok {
+				return !match(sub, value)
+			}
+		}
+
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return false
+		}
+
+		for k, sub := range p {
+			v, ok := obj[k]
+			if !ok || !match(sub, v) {
+				return false
+			}
+		}
+		return true
+
+	case []interface{}:
+		items, ok := value.([]interface{})
+		if !ok {
+			return false
+		}
+
+		for _, sub := range p {
+			found := false
+			for _, item := range items {
+				if match(sub, item) {
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				return false
+			}
+		}
+		return true
+	}
+
+	return reflect.DeepEqual(pattern, value)
+}
+
+func asSlice(v interface{}) []interface{} {
+	s, _ := v.([]interface{})
+	return s
+}
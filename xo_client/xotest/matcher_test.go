@@ -0,0 +1,74 @@
+package xotest
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	obj := `{
+		"type": "VM",
+		"name_label": "web",
+		"tags": ["prod", "web"],
+		"other": {"xo:resource_set": "team", "owner": "ops"},
+		"VIFs": [{"MAC": "02:00:00:00:00:01"}, {"MAC": "02:00:00:00:00:02"}],
+		"memory": 1073741824
+	}`
+
+	tests := []struct {
+		name    string
+		pattern string
+		want    bool
+	}{
+		{"equal", `{"name_label": "web"}`, true},
+		{"not equal", `{"name_label": "db"}`, false},
+		{"number", `{"memory": 1073741824}`, true},
+		{"missing property", `{"missing": "web"}`, false},
+		{"empty object", `{}`, true},
+		{"several properties", `{"type": "VM", "name_label": "web"}`, true},
+		{"one property differs", `{"type": "VM", "name_label": "db"}`, false},
+		{"partial nested object", `{"other": {"owner": "ops"}}`, true},
+		{"nested property differs", `{"other": {"owner": "dev"}}`, false},
+		{"object against scalar", `{"name_label": {"owner": "ops"}}`, false},
+
+		{"array element", `{"tags": ["prod"]}`, true},
+		{"array elements in any order", `{"tags": ["web", "prod"]}`, true},
+		{"array element missing", `{"tags": ["prod", "dev"]}`, false},
+		{"empty array", `{"tags": []}`, true},
+		{"array against scalar", `{"name_label": ["web"]}`, false},
+		{"array of partial objects", `{"VIFs": [{"MAC": "02:00:00:00:00:02"}]}`, true},
+		{"array of partial objects missing", `{"VIFs": [{"MAC": "02:00:00:00:00:03"}]}`, false},
+
+		{"and", `{"__and": [{"type": "VM"}, {"tags": ["web"]}]}`, true},
+		{"and one fails", `{"__and": [{"type": "VM"}, {"tags": ["dev"]}]}`, false},
+		{"and empty", `{"__and": []}`, true},
+		{"or", `{"__or": [{"name_label": "db"}, {"name_label": "web"}]}`, true},
+		{"or none", `{"__or": [{"name_label": "db"}, {"name_label": "cache"}]}`, false},
+		{"or empty", `{"__or": []}`, false},
+		{"not", `{"__not": {"name_label": "db"}}`, true},
+		{"not matching", `{"__not": {"name_label": "web"}}`, false},
+		{"not not", `{"__not": {"__not": {"name_label": "web"}}}`, true},
+		{"nested operators", `{"__and": [{"type": "VM"}, {"__or": [{"__not": {"tags": ["prod"]}}, {"other": {"owner": "ops"}}]}]}`, true},
+		{"operator on a property", `{"name_label": {"__or": ["db", "web"]}}`, true},
+		{"operator on a property fails", `{"name_label": {"__not": "web"}}`, false},
+		{"operator next to a property", `{"__not": {"name_label": "web"}, "type": "VM"}`, false},
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(obj), &value); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var pattern interface{}
+			if err := json.Unmarshal([]byte(test.pattern), &pattern); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := match(pattern, value); got != test.want {
+				t.Fatalf("expected match(%s) to be %t, got %t", test.pattern, test.want, got)
+			}
+		})
+	}
+}
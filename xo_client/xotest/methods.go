@@ -0,0 +1,916 @@
+package xotest
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// XO API error codes, see xo-common/api-errors
+const (
+	CodeNoSuchObject         = 1
+	CodeUnauthorized         = 2
+	CodeInvalidCredentials   = 3
+	CodeInvalidParameters    = 10
+	CodeVMMissingPVDrivers   = 11
+	CodeVMBadPowerState      = 13
+	CodeOperationBlocked     = 19
+	CodeXapiError            = -32000
+	gib                      = 1024 * 1024 * 1024
+	minMemory                = 128 * 1024 * 1024
+	maxDevicePosition        = 15
+	defaultMTU               = 1500
+	defaultLockingMode       = "network_default"
+	defaultVDIMode           = "RW"
+	notificationTypeEnter    = "enter"
+	notificationTypeExit     = "exit"
+	powerStateRunning        = "Running"
+	powerStateHalted         = "Halted"
+	templateBaseTemplateName = "base_template_name"
+)
+
+// APIError builds an error the way xo-server reports its own API errors
+func APIError(code int64, message string, data interface{}) *jsonrpc2.Error {
+	err := &jsonrpc2.Error{Code: code, Message: message}
+	if data != nil {
+		err.SetError(data)
+	}
+	return err
+}
+
+// XapiError builds an error the way xo-server forwards a failed XAPI call
+func XapiError(code string, params ...string) *jsonrpc2.Error {
+	if params == nil {
+		params = []string{}
+	}
+
+	return APIError(CodeXapiError, fmt.Sprintf("%s(%v)", code, params), map[string]interface{}{
+		"code":   code,
+		"params": params,
+	})
+}
+
+func noSuchObject(id, apiType string) *jsonrpc2.Error {
+	return APIError(CodeNoSuchObject, "no such object", map[string]interface{}{"id": id, "type": apiType})
+}
+
+func invalidParameters(format string, a ...interface{}) *jsonrpc2.Error {
+	return APIError(CodeInvalidParameters, "invalid parameters", map[string]interface{}{"error": fmt.Sprintf(format, a...)})
+}
+
+// tx is handed to the method implementations, it tracks what changed so clients can be
+// notified
+type tx struct {
+	s       *Server
+	conn    *jsonrpc2.Conn
+	entered map[string]interface{}
+	exited  map[string]interface{}
+}
+
+func (t *tx) put(obj map[string]interface{}) {
+	id := obj["id"].(string)
+	t.s.objects[id] = obj
+	t.entered[id] = struct{}{}
+	delete(t.exited, id)
+}
+
+func (t *tx) remove(id string) {
+	obj, ok := t.s.objects[id]
+	if !ok {
+		return
+	}
+
+	delete(t.s.objects, id)
+	delete(t.entered, id)
+	t.exited[id] = copyObject(obj)
+}
+
+func (t *tx) get(id, apiType string) (map[string]interface{}, error) {
+	obj, ok := t.s.objects[id]
+	if !ok || (len(apiType) > 0 && obj["type"] != apiType) {
+		return nil, noSuchObject(id, apiType)
+	}
+	return obj, nil
+}
+
+func (t *tx) createVDI(srID, name string, size int) string {
+	sr := t.s.objects[srID]
+
+	id := newID()
+	t.put(map[string]interface{}{
+		"type":             "VDI",
+		"id":               id,
+		"uuid":             id,
+		"name_label":       name,
+		"name_description": "",
+		"size":             float64(size),
+		"usage":            float64(0),
+		"VDI_type":         "user",
+		"$SR":              srID,
+		"$VBDs":            []interface{}{},
+		"$pool":            sr["$pool"],
+	})
+	return id
+}
+
+// createVBD links the VDI to the VM at position, new links are listed first like XO does
+func (t *tx) createVBD(vm map[string]interface{}, vdiID, position string, cdDrive, readOnly bool) string {
+	id := newID()
+	t.put(map[string]interface{}{
+		"type":        "VBD",
+		"id":          id,
+		"uuid":        id,
+		"attached":    false,
+		"bootable":    position == "0",
+		"device":      deviceName(position),
+		"is_cd_drive": cdDrive,
+		"position":    position,
+		"read_only":   readOnly,
+		"VDI":         vdiID,
+		"VM":          vm["id"],
+		"$pool":       vm["$pool"],
+	})
+
+	vm["$VBDs"] = prepend(vm["$VBDs"], id)
+	t.put(vm)
+
+	if vdi, ok := t.s.objects[vdiID]; ok {
+		vdi["$VBDs"] = prepend(vdi["$VBDs"], id)
+		t.put(vdi)
+	}
+
+	return id
+}
+
+func (t *tx) deleteVBD(vbd map[string]interface{}) {
+	id := vbd["id"].(string)
+
+	if vm, ok := t.s.objects[str(vbd, "VM")]; ok {
+		vm["$VBDs"] = without(vm["$VBDs"], id)
+		t.put(vm)
+	}
+
+	if vdi, ok := t.s.objects[str(vbd, "VDI")]; ok {
+		vdi["$VBDs"] = without(vdi["$VBDs"], id)
+		t.put(vdi)
+	}
+
+	t.remove(id)
+}
+
+func (t *tx) createVIF(vm map[string]interface{}, networkID, device, mac string, mtu int) string {
+	if len(device) == 0 {
+		device = t.freePosition(vm, "VIFs", "device")
+	}
+
+	if len(mac) == 0 {
+		mac = newMAC()
+	}
+
+	if mtu == 0 {
+		mtu = defaultMTU
+	}
+
+	id := newID()
+	t.put(map[string]interface{}{
+		"type":                 "VIF",
+		"id":                   id,
+		"uuid":                 id,
+		"attached":             vm["power_state"] == powerStateRunning,
+		"device":               device,
+		"MAC":                  mac,
+		"MTU":                  float64(mtu),
+		"lockingMode":          defaultLockingMode,
+		"allowedIpv4Addresses": []interface{}{},
+		"allowedIpv6Addresses": []interface{}{},
+		"$network":             networkID,
+		"$VM":                  vm["id"],
+		"$pool":                vm["$pool"],
+	})
+
+	vm["VIFs"] = prepend(vm["VIFs"], id)
+	t.put(vm)
+
+	return id
+}
+
+func (t *tx) deleteVIF(vif map[string]interface{}) {
+	id := vif["id"].(string)
+
+	if vm, ok := t.s.objects[str(vif, "$VM")]; ok {
+		vm["VIFs"] = without(vm["VIFs"], id)
+		t.put(vm)
+	}
+
+	t.remove(id)
+}
+
+// freePosition returns the lowest position not used by the objects linked from field
+func (t *tx) freePosition(vm map[string]interface{}, field, positionField string) string {
+	used := map[string]bool{}
+	for _, id := range asSlice(vm[field]) {
+		if obj, ok := t.s.objects[id.(string)]; ok {
+			used[str(obj, positionField)] = true
+		}
+	}
+
+	for i := 0; ; i++ {
+		position := strconv.Itoa(i)
+		if !used[position] {
+			return position
+		}
+	}
+}
+
+type method func(t *tx, params map[string]interface{}) (interface{}, error)
+
+var methods = map[string]method{
+	"session.signInWithPassword": signInWithPassword,
+	"session.signInWithToken":    signInWithToken,
+	"xo.getAllObjects":           getAllObjects,
+	"vm.create":                  vmCreate,
+	"vm.set":                     vmSet,
+	"vm.start":                   vmStart,
+	"vm.stop":                    vmStop,
+	"vm.delete":                  vmDelete,
+	"vm.attachDisk":              vmAttachDisk,
+	"vm.createInterface":         vmCreateInterface,
+	"disk.create":                diskCreate,
+	"vdi.set":                    vdiSet,
+	"vdi.delete":                 vdiDelete,
+	"vbd.connect":                vbdConnect,
+	"vbd.disconnect":             vbdDisconnect,
+	"vbd.delete":                 vbdDelete,
+	"vbd.set":                    vbdSet,
+	"vbd.setBootable":            vbdSetBootable,
+	"vif.connect":                vifConnect,
+	"vif.disconnect":             vifDisconnect,
+	"vif.delete":                 vifDelete,
+	"vif.set":                    vifSet,
+}
+
+func signInWithPassword(t *tx, params map[string]interface{}) (interface{}, error) {
+	email := str(params, "email")
+	password, ok := t.s.passwords[email]
+	if !ok || password != str(params, "password") {
+		return nil, APIError(CodeInvalidCredentials, "invalid credentials", nil)
+	}
+
+	t.s.conns[t.conn] = true
+	return map[string]interface{}{"id": email, "email": email, "permission": "admin"}, nil
+}
+
+func signInWithToken(t *tx, params map[string]interface{}) (interface{}, error) {
+	if !t.s.tokens[str(params, "token")] {
+		return nil, APIError(CodeInvalidCredentials, "invalid credentials", nil)
+	}
+
+	t.s.conns[t.conn] = true
+	return map[string]interface{}{"id": "token", "permission": "admin"}, nil
+}
+
+func getAllObjects(t *tx, params map[string]interface{}) (interface{}, error) {
+	filter, hasFilter := params["filter"]
+
+	objs := map[string]interface{}{}
+	for id, obj := range t.s.objects {
+		if !hasFilter || match(filter, obj) {
+			objs[id] = copyObject(obj)
+		}
+	}
+	return objs, nil
+}
+
+func vmCreate(t *tx, params map[string]interface{}) (interface{}, error) {
+	template, err := t.get(str(params, "template"), "VM-template")
+	if err != nil {
+		return nil, err
+	}
+
+	memory := num(params, "memory")
+	if memory == 0 {
+		memory = gib
+	}
+
+	cpus := num(params, "CPUs")
+	if cpus == 0 {
+		cpus = 1
+	}
+
+	id := newID()
+	vm := map[string]interface{}{
+		"type":             "VM",
+		"id":               id,
+		"uuid":             id,
+		"name_label":       str(params, "name_label"),
+		"name_description": str(params, "name_description"),
+		"CPUs":             map[string]interface{}{"max": float64(cpus), "number": float64(cpus)},
+		"memory": map[string]interface{}{
+			"size":    float64(memory),
+			"static":  []interface{}{float64(minMemory), float64(memory)},
+			"dynamic": []interface{}{float64(memory), float64(memory)},
+		},
+		"power_state":       powerStateHalted,
+		"pvDriversDetected": false,
+		"VIFs":              []interface{}{},
+		"$VBDs":             []interface{}{},
+		"other": map[string]interface{}{
+			templateBaseTemplateName: template["name_label"],
+		},
+		"$pool":      template["$pool"],
+		"$container": template["$pool"],
+	}
+	t.put(vm)
+
+	// clone the template disks, existingDisks overrides them by position
+	existingDisks, _ := params["existingDisks"].(map[string]interface{})
+	for _, vbdID := range reversed(template["$VBDs"]) {
+		templateVBD, ok := t.s.objects[vbdID]
+		if !ok || templateVBD["is_cd_drive"] == true {
+			continue
+		}
+
+		templateVDI, err := t.get(str(templateVBD, "VDI"), "VDI")
+		if err != nil {
+			return nil, err
+		}
+
+		position := str(templateVBD, "position")
+		name := str(templateVDI, "name_label")
+		srID := str(templateVDI, "$SR")
+		size := num(templateVDI, "size")
+
+		if override, ok := existingDisks[position].(map[string]interface{}); ok {
+			if v := str(override, "name_label"); len(v) > 0 {
+				name = v
+			}
+			if v := linkParam(override, "SR"); len(v) > 0 {
+				srID = v
+			}
+			if v := num(override, "size"); v > size {
+				size = v
+			}
+		}
+
+		if _, err := t.get(srID, "SR"); err != nil {
+			return nil, err
+		}
+
+		vdiID := t.createVDI(srID, name, size)
+		t.createVBD(vm, vdiID, position, false, false)
+	}
+
+	for _, disk := range asSlice(params["VDIs"]) {
+		diskMap, _ := disk.(map[string]interface{})
+		srID := linkParam(diskMap, "SR")
+		if _, err := t.get(srID, "SR"); err != nil {
+			return nil, err
+		}
+
+		vdiID := t.createVDI(srID, str(diskMap, "name_label"), num(diskMap, "size"))
+		t.createVBD(vm, vdiID, t.freePosition(vm, "$VBDs", "position"), false, false)
+	}
+
+	if installation, ok := params["installation"].(map[string]interface{}); ok && str(installation, "method") == "cd" {
+		if _, err := t.get(str(installation, "repository"), "VDI"); err != nil {
+			return nil, err
+		}
+		t.createVBD(vm, str(installation, "repository"), t.freePosition(vm, "$VBDs", "position"), true, true)
+	}
+
+	for _, vif := range asSlice(params["VIFs"]) {
+		vifMap, _ := vif.(map[string]interface{})
+		if _, err := t.get(str(vifMap, "network"), "network"); err != nil {
+			return nil, err
+		}
+		t.createVIF(vm, str(vifMap, "network"), "", str(vifMap, "mac"), num(vifMap, "mtu"))
+	}
+
+	if params["bootAfterCreate"] == true {
+		t.setPowerState(vm, powerStateRunning)
+	}
+
+	return id, nil
+}
+
+func vmSet(t *tx, params map[string]interface{}) (interface{}, error) {
+	vm, err := t.get(str(params, "id"), "VM")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, field := range []string{"name_label", "name_description"} {
+		if v, ok := params[field]; ok {
+			vm[field] = v
+		}
+	}
+
+	cpus := vm["CPUs"].(map[string]interface{})
+	if v, ok := params["cpusMax"]; ok {
+		if vm["power_state"] != powerStateHalted && v.(float64) != cpus["max"] {
+			return nil, XapiError("VM_BAD_POWER_STATE", str(vm, "id"), "halted", lower(vm["power_state"]))
+		}
+		cpus["max"] = v
+	}
+	if v, ok := params["CPUs"]; ok {
+		if v.(float64) > cpus["max"].(float64) {
+			if vm["power_state"] != powerStateHalted {
+				return nil, invalidParameters("CPUs cannot exceed cpusMax while running")
+			}
+			cpus["max"] = v
+		}
+		cpus["number"] = v
+	}
+
+	memory := vm["memory"].(map[string]interface{})
+	static := asSlice(memory["static"])
+	dynamic := asSlice(memory["dynamic"])
+	if v, ok := params["memoryStaticMax"]; ok {
+		if vm["power_state"] != powerStateHalted && v.(float64) != static[1] {
+			return nil, XapiError("VM_BAD_POWER_STATE", str(vm, "id"), "halted", lower(vm["power_state"]))
+		}
+		static[1] = v
+	}
+	if v, ok := params["memoryMin"]; ok {
+		dynamic[0] = v
+	}
+	for _, field := range []string{"memory", "memoryMax"} {
+		if v, ok := params[field]; ok {
+			dynamic[1] = v
+		}
+	}
+	if dynamic[1].(float64) > static[1].(float64) {
+		if vm["power_state"] != powerStateHalted {
+			return nil, invalidParameters("memoryMax cannot exceed memoryStaticMax while running")
+		}
+		static[1] = dynamic[1]
+	}
+	if dynamic[0].(float64) > dynamic[1].(float64) {
+		return nil, invalidParameters("memoryMin cannot exceed memoryMax")
+	}
+	memory["size"] = dynamic[1]
+
+	t.put(vm)
+	return true, nil
+}
+
+func vmStart(t *tx, params map[string]interface{}) (interface{}, error) {
+	vm, err := t.get(str(params, "id"), "VM")
+	if err != nil {
+		return nil, err
+	}
+
+	if vm["power_state"] != powerStateHalted {
+		return nil, XapiError("VM_BAD_POWER_STATE", str(vm, "id"), "halted", lower(vm["power_state"]))
+	}
+
+	t.setPowerState(vm, powerStateRunning)
+	return true, nil
+}
+
+func vmStop(t *tx, params map[string]interface{}) (interface{}, error) {
+	vm, err := t.get(str(params, "id"), "VM")
+	if err != nil {
+		return nil, err
+	}
+
+	if vm["power_state"] != powerStateRunning {
+		return nil, XapiError("VM_BAD_POWER_STATE", str(vm, "id"), "running", lower(vm["power_state"]))
+	}
+
+	// a clean shutdown needs the guest to cooperate
+	if params["force"] != true && vm["pvDriversDetected"] != true {
+		return nil, APIError(CodeVMMissingPVDrivers, "missing PV drivers", map[string]interface{}{"vm": vm["id"]})
+	}
+
+	t.setPowerState(vm, powerStateHalted)
+	return true, nil
+}
+
+func (t *tx) setPowerState(vm map[string]interface{}, powerState string) {
+	running := powerState == powerStateRunning
+
+	vm["power_state"] = powerState
+	if running {
+		vm["pvDriversDetected"] = t.s.pvDrivers
+	}
+	t.put(vm)
+
+	for _, field := range []string{"$VBDs", "VIFs"} {
+		for _, id := range asSlice(vm[field]) {
+			if obj, ok := t.s.objects[id.(string)]; ok {
+				obj["attached"] = running
+				t.put(obj)
+			}
+		}
+	}
+}
+
+func vmDelete(t *tx, params map[string]interface{}) (interface{}, error) {
+	vm, err := t.get(str(params, "id"), "VM")
+	if err != nil {
+		return nil, err
+	}
+
+	if vm["power_state"] != powerStateHalted {
+		return nil, XapiError("VM_BAD_POWER_STATE", str(vm, "id"), "halted", lower(vm["power_state"]))
+	}
+
+	for _, id := range asSlice(vm["$VBDs"]) {
+		vbd, ok := t.s.objects[id.(string)]
+		if !ok {
+			continue
+		}
+
+		vdiID := str(vbd, "VDI")
+		t.deleteVBD(vbd)
+
+		if params["delete_disks"] == true && vbd["is_cd_drive"] != true {
+			t.remove(vdiID)
+		}
+	}
+
+	for _, id := range asSlice(vm["VIFs"]) {
+		if vif, ok := t.s.objects[id.(string)]; ok {
+			t.deleteVIF(vif)
+		}
+	}
+
+	t.remove(str(vm, "id"))
+	return true, nil
+}
+
+func vmAttachDisk(t *tx, params map[string]interface{}) (interface{}, error) {
+	vm, err := t.get(str(params, "vm"), "VM")
+	if err != nil {
+		return nil, err
+	}
+
+	vdi, err := t.get(str(params, "vdi"), "VDI")
+	if err != nil {
+		return nil, err
+	}
+
+	if vm["power_state"] == powerStateRunning && vm["pvDriversDetected"] != true {
+		return nil, XapiError("VM_MISSING_PV_DRIVERS", str(vm, "id"))
+	}
+
+	position := str(params, "position")
+	if len(position) == 0 {
+		position = t.freePosition(vm, "$VBDs", "position")
+	}
+
+	for _, id := range asSlice(vm["$VBDs"]) {
+		if vbd, ok := t.s.objects[id.(string)]; ok && str(vbd, "position") == position {
+			return nil, XapiError("DEVICE_ALREADY_EXISTS", position)
+		}
+	}
+
+	if p, _ := strconv.Atoi(position); p > maxDevicePosition {
+		return nil, XapiError("INVALID_DEVICE", position)
+	}
+
+	mode := str(params, "mode")
+	if len(mode) == 0 {
+		mode = defaultVDIMode
+	}
+
+	vbdID := t.createVBD(vm, str(vdi, "id"), position, false, mode == "RO")
+
+	vbd := t.s.objects[vbdID]
+	vbd["attached"] = vm["power_state"] == powerStateRunning
+	if v, ok := params["bootable"].(bool); ok {
+		vbd["bootable"] = v
+	}
+	t.put(vbd)
+
+	return true, nil
+}
+
+func vmCreateInterface(t *tx, params map[string]interface{}) (interface{}, error) {
+	vm, err := t.get(str(params, "vm"), "VM")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := t.get(str(params, "network"), "network"); err != nil {
+		return nil, err
+	}
+
+	if vm["power_state"] == powerStateRunning && vm["pvDriversDetected"] != true {
+		return nil, XapiError("VM_MISSING_PV_DRIVERS", str(vm, "id"))
+	}
+
+	position := str(params, "position")
+	for _, id := range asSlice(vm["VIFs"]) {
+		if vif, ok := t.s.objects[id.(string)]; ok && len(position) > 0 && str(vif, "device") == position {
+			return nil, XapiError("DEVICE_ALREADY_EXISTS", position)
+		}
+	}
+
+	return t.createVIF(vm, str(params, "network"), position, str(params, "mac"), num(params, "mtu")), nil
+}
+
+func diskCreate(t *tx, params map[string]interface{}) (interface{}, error) {
+	if _, err := t.get(str(params, "sr"), "SR"); err != nil {
+		return nil, err
+	}
+
+	size := num(params, "size")
+	if size <= 0 {
+		return nil, invalidParameters("size must be positive")
+	}
+
+	vdiID := t.createVDI(str(params, "sr"), str(params, "name"), size)
+
+	if vmID := str(params, "vm"); len(vmID) > 0 {
+		attachParams := map[string]interface{}{
+			"vm":       vmID,
+			"vdi":      vdiID,
+			"mode":     params["mode"],
+			"bootable": params["bootable"],
+			"position": params["position"],
+		}
+		if _, err := vmAttachDisk(t, attachParams); err != nil {
+			return nil, err
+		}
+	}
+
+	return vdiID, nil
+}
+
+func vdiSet(t *tx, params map[string]interface{}) (interface{}, error) {
+	vdi, err := t.get(str(params, "id"), "VDI")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, field := range []string{"name_label", "name_description"} {
+		if v, ok := params[field]; ok {
+			vdi[field] = v
+		}
+	}
+
+	if _, ok := params["size"]; ok {
+		size := num(params, "size")
+		if size < num(vdi, "size") {
+			return nil, XapiError("VDI_SIZE_TOO_SMALL", str(vdi, "id"))
+		}
+		vdi["size"] = float64(size)
+	}
+
+	t.put(vdi)
+	return true, nil
+}
+
+func vdiDelete(t *tx, params map[string]interface{}) (interface{}, error) {
+	vdi, err := t.get(str(params, "id"), "VDI")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range asSlice(vdi["$VBDs"]) {
+		if vbd, ok := t.s.objects[id.(string)]; ok && vbd["attached"] == true {
+			return nil, XapiError("VDI_IN_USE", str(vdi, "id"), "destroy")
+		}
+	}
+
+	for _, id := range asSlice(vdi["$VBDs"]) {
+		if vbd, ok := t.s.objects[id.(string)]; ok {
+			t.deleteVBD(vbd)
+		}
+	}
+
+	t.remove(str(vdi, "id"))
+	return true, nil
+}
+
+func vbdConnect(t *tx, params map[string]interface{}) (interface{}, error) {
+	return setAttached(t, str(params, "id"), "VBD", true)
+}
+
+func vbdDisconnect(t *tx, params map[string]interface{}) (interface{}, error) {
+	return setAttached(t, str(params, "id"), "VBD", false)
+}
+
+func vbdDelete(t *tx, params map[string]interface{}) (interface{}, error) {
+	vbd, err := t.get(str(params, "id"), "VBD")
+	if err != nil {
+		return nil, err
+	}
+
+	if vbd["attached"] == true {
+		return nil, XapiError("OPERATION_NOT_ALLOWED", "VBD is currently attached")
+	}
+
+	t.deleteVBD(vbd)
+	return true, nil
+}
+
+func vbdSet(t *tx, params map[string]interface{}) (interface{}, error) {
+	vbd, err := t.get(str(params, "id"), "VBD")
+	if err != nil {
+		return nil, err
+	}
+
+	if v, ok := params["position"]; ok {
+		if vbd["attached"] == true {
+			return nil, XapiError("DEVICE_ALREADY_ATTACHED", str(vbd, "id"))
+		}
+		vbd["position"] = v
+		vbd["device"] = deviceName(str(vbd, "position"))
+	}
+
+	t.put(vbd)
+	return true, nil
+}
+
+func vbdSetBootable(t *tx, params map[string]interface{}) (interface{}, error) {
+	vbd, err := t.get(str(params, "vbd"), "VBD")
+	if err != nil {
+		return nil, err
+	}
+
+	bootable, ok := params["bootable"].(bool)
+	if !ok {
+		return nil, invalidParameters("bootable must be a boolean")
+	}
+
+	vbd["bootable"] = bootable
+	t.put(vbd)
+	return true, nil
+}
+
+func vifConnect(t *tx, params map[string]interface{}) (interface{}, error) {
+	return setAttached(t, str(params, "id"), "VIF", true)
+}
+
+func vifDisconnect(t *tx, params map[string]interface{}) (interface{}, error) {
+	return setAttached(t, str(params, "id"), "VIF", false)
+}
+
+func vifDelete(t *tx, params map[string]interface{}) (interface{}, error) {
+	vif, err := t.get(str(params, "id"), "VIF")
+	if err != nil {
+		return nil, err
+	}
+
+	if vif["attached"] == true {
+		return nil, XapiError("OPERATION_NOT_ALLOWED", "VIF is currently attached")
+	}
+
+	t.deleteVIF(vif)
+	return true, nil
+}
+
+func vifSet(t *tx, params map[string]interface{}) (interface{}, error) {
+	vif, err := t.get(str(params, "id"), "VIF")
+	if err != nil {
+		return nil, err
+	}
+
+	if v, ok := params["network"]; ok {
+		if _, err := t.get(v.(string), "network"); err != nil {
+			return nil, err
+		}
+		vif["$network"] = v
+	}
+
+	if v, ok := params["mac"]; ok {
+		vif["MAC"] = v
+	}
+
+	for _, field := range []string{"allowedIpv4Addresses", "allowedIpv6Addresses", "lockingMode"} {
+		if v, ok := params[field]; ok {
+			vif[field] = v
+		}
+	}
+
+	if v, ok := params["rateLimit"]; ok {
+		if v == nil {
+			delete(vif, "rateLimit")
+		} else {
+			vif["rateLimit"] = v
+		}
+	}
+
+	t.put(vif)
+	return true, nil
+}
+
+func setAttached(t *tx, id, apiType string, attached bool) (interface{}, error) {
+	obj, err := t.get(id, apiType)
+	if err != nil {
+		return nil, err
+	}
+
+	if obj["attached"] == attached {
+		if attached {
+			return nil, XapiError("DEVICE_ALREADY_ATTACHED", id)
+		}
+		return nil, XapiError("DEVICE_ALREADY_DETACHED", id)
+	}
+
+	vm, err := t.get(str(obj, vmLink(apiType)), "VM")
+	if err != nil {
+		return nil, err
+	}
+
+	if vm["power_state"] != powerStateRunning {
+		return nil, XapiError("VM_BAD_POWER_STATE", str(vm, "id"), "running", lower(vm["power_state"]))
+	}
+
+	if vm["pvDriversDetected"] != true {
+		return nil, XapiError("VM_MISSING_PV_DRIVERS", str(vm, "id"))
+	}
+
+	obj["attached"] = attached
+	t.put(obj)
+	return true, nil
+}
+
+func vmLink(apiType string) string {
+	if apiType == "VIF" {
+		return "$VM"
+	}
+	return "VM"
+}
+
+// linkParam reads a link that xo-server accepts both with and without the $ prefix
+func linkParam(params map[string]interface{}, field string) string {
+	if v := str(params, "$"+field); len(v) > 0 {
+		return v
+	}
+	return str(params, field)
+}
+
+func str(obj map[string]interface{}, field string) string {
+	switch v := obj[field].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
+
+func num(obj map[string]interface{}, field string) int {
+	switch v := obj[field].(type) {
+	case float64:
+		return int(v)
+	case string:
+		i, _ := strconv.Atoi(v)
+		return i
+	case json.Number:
+		i, _ := v.Int64()
+		return int(i)
+	}
+	return 0
+}
+
+func lower(v interface{}) string {
+	s, _ := v.(string)
+	b := []byte(s)
+	for i, c := range b {
+		if c >= 'A' && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return string(b)
+}
+
+func deviceName(position string) string {
+	p, err := strconv.Atoi(position)
+	if err != nil || p < 0 || p > 25 {
+		return ""
+	}
+	return "xvd" + string(rune('a'+p))
+}
+
+func prepend(list interface{}, id string) []interface{} {
+	return append([]interface{}{id}, asSlice(list)...)
+}
+
+func without(list interface{}, id string) []interface{} {
+	var out []interface{}
+	for _, v := range asSlice(list) {
+		if v != id {
+			out = append(out, v)
+		}
+	}
+	if out == nil {
+		out = []interface{}{}
+	}
+	return out
+}
+
+// reversed returns the ids of a link list oldest first
+func reversed(list interface{}) []string {
+	var ids []string
+	for _, v := range asSlice(list) {
+		ids = append([]string{v.(string)}, ids...)
+	}
+	return ids
+}
@@ -0,0 +1,454 @@
+// Package xotest provides an in-memory Xen Orchestra JSON-RPC server for testing xo_client
+// and the provider without a real XOA.
+package xotest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	gws "github.com/gorilla/websocket"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/sourcegraph/jsonrpc2/websocket"
+)
+
+// Fault makes the server misbehave on matching calls
+type Fault struct {
+	// Method the fault applies to, every method when empty
+	Method string
+	// Times is how many calls fail before the fault is cleared, 0 fails every call
+	Times int
+	// Err is returned instead of handling the call
+	Err *jsonrpc2.Error
+	// Delay is waited before handling the call
+	Delay time.Duration
+	// Disconnect drops the connection instead of responding
+	Disconnect bool
+}
+
+// Call is a request received by the server
+type Call struct {
+	Method string
+	Params map[string]interface{}
+}
+
+type Server struct {
+	// URL is the ws:// URL to hand to xo_client.NewClient
+	URL *url.URL
+
+	httpServer *httptest.Server
+	upgrader   gws.Upgrader
+
+	mu        sync.Mutex
+	objects   map[string]map[string]interface{}
+	passwords map[string]string
+	tokens    map[string]bool
+	conns     map[*jsonrpc2.Conn]bool
+	faults    []*Fault
+	calls     []Call
+	pvDrivers bool
+}
+
+func NewServer() *Server {
+	s := &Server{
+		objects:   map[string]map[string]interface{}{},
+		passwords: map[string]string{},
+		tokens:    map[string]bool{},
+		conns:     map[*jsonrpc2.Conn]bool{},
+	}
+
+	s.httpServer = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+
+	u, _ := url.Parse(s.httpServer.URL)
+	u.Scheme = "ws"
+	s.URL = u
+
+	return s
+}
+
+func (s *Server) Close() {
+	s.DisconnectAll()
+	s.httpServer.Close()
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	ws, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	conn := jsonrpc2.NewConn(context.Background(), websocket.NewObjectStream(ws), jsonrpc2.HandlerWithError(s.handle).SuppressErrClosed())
+
+	s.mu.Lock()
+	s.conns[conn] = false
+	s.mu.Unlock()
+
+	<-conn.DisconnectNotify()
+
+	s.mu.Lock()
+	delete(s.conns, conn)
+	s.mu.Unlock()
+}
+
+// AddUser allows signing in with the email and password
+func (s *Server) AddUser(email, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.passwords[email] = password
+}
+
+// AddToken allows signing in with the authentication token
+func (s *Server) AddToken(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[token] = true
+}
+
+// SetPVDriversDetected controls whether VMs report PV drivers once they are started
+func (s *Server) SetPVDriversDetected(detected bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pvDrivers = detected
+}
+
+// InjectFault makes matching calls fail until the fault is used up or cleared
+func (s *Server) InjectFault(fault Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = append(s.faults, &fault)
+}
+
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = nil
+}
+
+// DisconnectAll drops every client connection, like an xo-server restart would
+func (s *Server) DisconnectAll() {
+	s.mu.Lock()
+	var conns []*jsonrpc2.Conn
+	for conn := range s.conns {
+		conns = append(conns, conn)
+	}
+	s.mu.Unlock()
+
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
+
+// Calls returns the calls received for method, or every call when method is empty
+func (s *Server) Calls(method string) []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var calls []Call
+	for _, call := range s.calls {
+		if len(method) == 0 || call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Object returns a copy of the object, nil when it doesn't exist
+func (s *Server) Object(id string) map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	obj, ok := s.objects[id]
+	if !ok {
+		return nil
+	}
+	return copyObject(obj)
+}
+
+// ObjectsOfType returns copies of all objects of the XO type
+func (s *Server) ObjectsOfType(apiType string) map[string]map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	objs := map[string]map[string]interface{}{}
+	for id, obj := range s.objects {
+		if obj["type"] == apiType {
+			objs[id] = copyObject(obj)
+		}
+	}
+	return objs
+}
+
+// AddObject stores obj, generating an id if it doesn't have one, and announces it to the
+// connected clients
+func (s *Server) AddObject(obj map[string]interface{}) string {
+	obj = copyObject(obj)
+	if _, ok := obj["id"]; !ok {
+		obj["id"] = newID()
+	}
+	id := obj["id"].(string)
+	if _, ok := obj["uuid"]; !ok {
+		obj["uuid"] = id
+	}
+
+	s.mutate(func(t *tx) {
+		t.put(obj)
+	})
+	return id
+}
+
+// UpdateObject changes fields of an object out of band
+func (s *Server) UpdateObject(id string, fields map[string]interface{}) error {
+	var err error
+	s.mutate(func(t *tx) {
+		obj, ok := s.objects[id]
+		if !ok {
+			err = fmt.Errorf("no object with id %s", id)
+			return
+		}
+
+		for k, v := range copyObject(fields) {
+			obj[k] = v
+		}
+		t.put(obj)
+	})
+	return err
+}
+
+// DeleteObject removes an object out of band, links to it are left dangling
+func (s *Server) DeleteObject(id string) {
+	s.mutate(func(t *tx) {
+		t.remove(id)
+	})
+}
+
+func (s *Server) AddPool(name string) string {
+	return s.AddObject(map[string]interface{}{
+		"type":             "pool",
+		"name_label":       name,
+		"name_description": "",
+	})
+}
+
+func (s *Server) AddStorageRepository(poolID, name, srType string) string {
+	return s.AddObject(map[string]interface{}{
+		"type":             "SR",
+		"name_label":       name,
+		"name_description": "",
+		"SR_type":          srType,
+		"$pool":            poolID,
+		"$container":       poolID,
+	})
+}
+
+func (s *Server) AddNetwork(poolID, name string) string {
+	return s.AddObject(map[string]interface{}{
+		"type":             "network",
+		"name_label":       name,
+		"name_description": "",
+		"MTU":              1500,
+		"$pool":            poolID,
+	})
+}
+
+func (s *Server) AddVDI(srID, name string, size int) string {
+	var id string
+	s.mutate(func(t *tx) {
+		id = t.createVDI(srID, name, size)
+	})
+	return id
+}
+
+// TemplateDisk describes a disk attached to a template
+type TemplateDisk struct {
+	StorageRepositoryID string
+	Size                int
+}
+
+// AddTemplate creates a template with the disks, a template without disks requires
+// installation
+func (s *Server) AddTemplate(poolID, name string, disks ...TemplateDisk) string {
+	id := newID()
+
+	s.mutate(func(t *tx) {
+		template := map[string]interface{}{
+			"type":             "VM-template",
+			"id":               id,
+			"uuid":             id,
+			"name_label":       name,
+			"name_description": "",
+			"CPUs":             map[string]interface{}{"max": 1, "number": 1},
+			"memory":           map[string]interface{}{"size": gib, "static": []interface{}{minMemory, gib}, "dynamic": []interface{}{gib, gib}},
+			"power_state":      powerStateHalted,
+			"VIFs":             []interface{}{},
+			"$VBDs":            []interface{}{},
+			"template_info":    map[string]interface{}{"disks": []interface{}{}},
+			"$pool":            poolID,
+		}
+		template = copyObject(template)
+		t.put(template)
+
+		for i, disk := range disks {
+			vdiID := t.createVDI(disk.StorageRepositoryID, fmt.Sprintf("%s %d", name, i), disk.Size)
+			t.createVBD(template, vdiID, fmt.Sprintf("%d", i), false, false)
+		}
+	})
+
+	return id
+}
+
+func (s *Server) handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (interface{}, error) {
+	params := map[string]interface{}{}
+	if req.Params != nil {
+		err := json.Unmarshal(*req.Params, &params)
+		if err != nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: err.Error()}
+		}
+	}
+
+	s.mu.Lock()
+	s.calls = append(s.calls, Call{Method: req.Method, Params: copyObject(params)})
+	fault := s.takeFault(req.Method)
+	signedIn := s.conns[conn]
+	s.mu.Unlock()
+
+	if fault != nil {
+		if fault.Delay > 0 {
+			time.Sleep(fault.Delay)
+		}
+
+		if fault.Disconnect {
+			conn.Close()
+			return nil, jsonrpc2.ErrClosed
+		}
+
+		if fault.Err != nil {
+			return nil, fault.Err
+		}
+	}
+
+	method, ok := methods[req.Method]
+	if !ok {
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeMethodNotFound, Message: fmt.Sprintf("method not found: %s", req.Method)}
+	}
+
+	if !signedIn && req.Method != "session.signInWithPassword" && req.Method != "session.signInWithToken" {
+		return nil, APIError(CodeUnauthorized, "not authenticated", nil)
+	}
+
+	var result interface{}
+	var err error
+	s.mutate(func(t *tx) {
+		t.conn = conn
+		result, err = method(t, params)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *Server) takeFault(method string) *Fault {
+	for i, fault := range s.faults {
+		if len(fault.Method) > 0 && fault.Method != method {
+			continue
+		}
+
+		if fault.Times > 0 {
+			fault.Times--
+			if fault.Times == 0 {
+				s.faults = append(s.faults[:i], s.faults[i+1:]...)
+			}
+		}
+
+		return fault
+	}
+
+	return nil
+}
+
+// mutate runs f with the lock held and announces the objects it changed afterwards
+func (s *Server) mutate(f func(t *tx)) {
+	t := &tx{
+		s:       s,
+		entered: map[string]interface{}{},
+		exited:  map[string]interface{}{},
+	}
+
+	s.mu.Lock()
+	f(t)
+
+	// snapshot under the lock, the objects keep changing once it is released
+	entered := map[string]interface{}{}
+	for id := range t.entered {
+		if obj, ok := s.objects[id]; ok {
+			entered[id] = copyObject(obj)
+		}
+	}
+
+	var conns []*jsonrpc2.Conn
+	for conn, signedIn := range s.conns {
+		if signedIn {
+			conns = append(conns, conn)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, conn := range conns {
+		if len(entered) > 0 {
+			conn.Notify(context.Background(), "all", map[string]interface{}{"type": notificationTypeEnter, "items": entered})
+		}
+		if len(t.exited) > 0 {
+			conn.Notify(context.Background(), "all", map[string]interface{}{"type": notificationTypeExit, "items": t.exited})
+		}
+	}
+}
+
+func copyObject(obj map[string]interface{}) map[string]interface{} {
+	b, err := json.Marshal(obj)
+	if err != nil {
+		panic(fmt.Sprintf("xotest: unable to copy object: %v", err))
+	}
+
+	var c map[string]interface{}
+	err = json.Unmarshal(b, &c)
+	if err != nil {
+		panic(fmt.Sprintf("xotest: unable to copy object: %v", err))
+	}
+	return c
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		panic(err)
+	}
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+func newMAC() string {
+	b := make([]byte, 6)
+	_, err := rand.Read(b)
+	if err != nil {
+		panic(err)
+	}
+
+	// locally administered unicast
+	b[0] = (b[0] | 0x02) & 0xfe
+	return fmt.Sprintf("%02x:%02x:%02x:%02x:%02x:%02x", b[0], b[1], b[2], b[3], b[4], b[5])
+}